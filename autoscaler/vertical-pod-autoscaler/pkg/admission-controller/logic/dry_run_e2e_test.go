@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	policy_v1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1alpha1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/limitrange"
+	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+// fakeRecommendationProvider always recommends a single "main" container be
+// given a 100m CPU request, for a VPA named "some-vpa" in Auto mode - enough
+// to always produce a non-empty patch for getPatchesForPodResourceRequest to
+// compute, so a test can tell dry-run suppression apart from "nothing to do".
+type fakeRecommendationProvider struct{}
+
+func (fakeRecommendationProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error) {
+	resources := make([]vpa_api_util.ContainerResources, len(pod.Spec.Containers))
+	for i := range resources {
+		resources[i] = vpa_api_util.ContainerResources{
+			Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+		}
+	}
+	return resources, vpa_api_util.ContainerToAnnotationsMap{}, "some-vpa", vpa_types.UpdateModeAuto, nil
+}
+
+func (fakeRecommendationProvider) ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error {
+	return nil
+}
+
+type passthroughPodPreProcessor struct{}
+
+func (passthroughPodPreProcessor) Process(pod core.Pod) (core.Pod, error) { return pod, nil }
+
+type passthroughVpaPreProcessor struct{}
+
+func (passthroughVpaPreProcessor) Process(vpa vpa_types.VerticalPodAutoscaler, isCreate bool) (vpa_types.VerticalPodAutoscaler, error) {
+	return vpa, nil
+}
+
+// fixedPolicyProvider returns spec for every namespace, regardless of name -
+// enough for a test that only cares about one namespace's policy.
+type fixedPolicyProvider struct {
+	spec *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec
+}
+
+func (p fixedPolicyProvider) PolicyFor(namespace string) *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec {
+	return p.spec
+}
+
+func newTestAdmissionServer(policyProvider AdmissionPolicyProvider) *AdmissionServer {
+	return NewAdmissionServer(
+		fakeRecommendationProvider{},
+		passthroughPodPreProcessor{},
+		passthroughVpaPreProcessor{},
+		limitrange.NewNoopLimitsCalculator(),
+		false, /* inPlaceResizeSupported */
+		0.10,
+		context.Background(),
+		policyProvider,
+		nil, /* eventRecorder */
+	)
+}
+
+func podCreateAdmissionReview(t *testing.T, podJSON []byte) []byte {
+	t.Helper()
+	ar := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       apitypes.UID("test-uid"),
+			Namespace: "default",
+			Operation: admissionv1.Create,
+			Resource:  metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"},
+			Object:    runtime.RawExtension{Raw: podJSON},
+		},
+	}
+	body, err := json.Marshal(ar)
+	if err != nil {
+		t.Fatalf("failed to marshal AdmissionReview: %v", err)
+	}
+	return body
+}
+
+// TestDryRunPolicySuppressesPatchButIsObservable exercises AdmissionServer.admit
+// end to end for a namespace whose AdmissionPolicy sets DryRun: true - the
+// webhook must compute patches as usual (so the feature is provably doing
+// something) but return none of them, while still recording the admission as
+// Skipped rather than Error, and recording a policy-dry-run observation.
+func TestDryRunPolicySuppressesPatchButIsObservable(t *testing.T) {
+	s := newTestAdmissionServer(fixedPolicyProvider{spec: &policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec{DryRun: true}})
+
+	pod := core.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec:       core.PodSpec{Containers: []core.Container{{Name: "main"}}},
+	}
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("failed to marshal pod: %v", err)
+	}
+
+	response, status, resource := s.admit(context.Background(), podCreateAdmissionReview(t, podJSON))
+
+	if !response.Allowed {
+		t.Errorf("expected dry-run admission to still allow the pod, got Allowed=false")
+	}
+	if response.Patch != nil {
+		t.Errorf("expected no patch to be returned in dry-run mode, got %s", response.Patch)
+	}
+	if status != metrics_admission.Skipped {
+		t.Errorf("expected status Skipped for a dry-run admission, got %v", status)
+	}
+	if resource != metrics_admission.Pod {
+		t.Errorf("expected resource Pod, got %v", resource)
+	}
+}
+
+// TestNonDryRunPolicyStillPatches is the control case: the same pod and
+// recommendation, but no DryRun policy in effect, must actually produce a
+// patch - otherwise the dry-run test above would pass for the wrong reason
+// (e.g. the fake recommendation provider never recommending anything).
+func TestNonDryRunPolicyStillPatches(t *testing.T) {
+	s := newTestAdmissionServer(nil)
+
+	pod := core.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-pod", Namespace: "default"},
+		Spec:       core.PodSpec{Containers: []core.Container{{Name: "main"}}},
+	}
+	podJSON, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatalf("failed to marshal pod: %v", err)
+	}
+
+	response, status, _ := s.admit(context.Background(), podCreateAdmissionReview(t, podJSON))
+
+	if response.Patch == nil {
+		t.Errorf("expected a patch to be returned without a dry-run policy in effect")
+	}
+	if status != metrics_admission.Applied {
+		t.Errorf("expected status Applied, got %v", status)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+)
+
+var (
+	admissionEventRingSize = flag.Int("admissions-watch-buffer-size", 200,
+		"Number of past admission decisions kept in memory for the /admissions/watch debug endpoint.")
+	admissionWatchPollInterval = flag.Duration("admissions-watch-poll-interval", time.Second,
+		"How often a connected /admissions/watch client is checked for new events to send.")
+)
+
+// AdmissionEvent is one entry of the debug event stream served at
+// /admissions/watch - a compact record of what the admission server decided
+// about a single request, independent of (and much less detailed than) the
+// Prometheus metrics recorded alongside it.
+type AdmissionEvent struct {
+	// ResourceVersion is a monotonically increasing counter, scoped to this
+	// process's lifetime, identifying this event's position in the stream.
+	// Callers resume a watch by passing the last ResourceVersion they saw as
+	// the ?resourceVersion= query parameter.
+	ResourceVersion uint64      `json:"resourceVersion"`
+	Timestamp       metav1.Time `json:"timestamp"`
+	Namespace       string      `json:"namespace,omitempty"`
+	Name            string      `json:"name,omitempty"`
+	// VPAName is the VerticalPodAutoscaler that matched this pod, if any -
+	// only ever set for pod admissions.
+	VPAName        string  `json:"vpaName,omitempty"`
+	Resource       string  `json:"resource"`
+	Allowed        bool    `json:"allowed"`
+	Patched        bool    `json:"patched"`
+	LatencySeconds float64 `json:"latencySeconds"`
+	// Message summarizes what changed (for an applied patch) or why the
+	// request was rejected (for a denied VPA admission).
+	Message string `json:"message,omitempty"`
+}
+
+// admissionEventRing is a fixed-capacity, overwrite-oldest ring buffer of
+// AdmissionEvents, so a slow or absent /admissions/watch consumer can never
+// make the admission server's memory usage grow unbounded - the oldest
+// events are simply dropped, the same tradeoff client-go informers make when
+// a watch falls far enough behind that the apiserver's own event history has
+// already rolled past it.
+// admissionEventRing是一个固定容量、覆盖最旧数据的环形缓冲区，用来存放
+// AdmissionEvent，这样一个缓慢或者根本不存在的/admissions/watch消费者就永远
+// 不会让admission server的内存占用无限增长——最旧的事件会被直接丢弃，这和
+// client-go informer在watch落后太多、apiserver自己的事件历史已经把它滚动过去
+// 之后所做的取舍是一样的。
+type admissionEventRing struct {
+	mu      sync.Mutex
+	buf     []AdmissionEvent
+	next    int
+	count   int
+	counter uint64
+}
+
+func newAdmissionEventRing(capacity int) *admissionEventRing {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &admissionEventRing{buf: make([]AdmissionEvent, capacity)}
+}
+
+// Record assigns event the next ResourceVersion and stores it, evicting the
+// oldest entry if the ring is full.
+func (r *admissionEventRing) Record(event AdmissionEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counter++
+	event.ResourceVersion = r.counter
+	r.buf[r.next] = event
+	r.next = (r.next + 1) % len(r.buf)
+	if r.count < len(r.buf) {
+		r.count++
+	}
+}
+
+// Latest returns the ResourceVersion of the most recently recorded event (0
+// if none have been recorded yet) - a client that omits ?resourceVersion=
+// starts its watch from here, seeing only events recorded from now on.
+func (r *admissionEventRing) Latest() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counter
+}
+
+// Since returns every currently-buffered event with a ResourceVersion
+// greater than resourceVersion, oldest first. If resourceVersion is older
+// than every buffered event (it fell off the ring), Since simply returns
+// everything it still has - same best-effort semantics as Latest, there is
+// no "resourceVersion too old" error here.
+func (r *admissionEventRing) Since(resourceVersion uint64) []AdmissionEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := make([]AdmissionEvent, 0, r.count)
+	start := (r.next - r.count + len(r.buf)) % len(r.buf)
+	for i := 0; i < r.count; i++ {
+		event := r.buf[(start+i)%len(r.buf)]
+		if event.ResourceVersion > resourceVersion {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// WatchAdmissions serves /admissions/watch: a newline-delimited JSON stream
+// of AdmissionEvents, using chunked transfer encoding and periodic polling of
+// the ring buffer - a long-poll, not a true push, but the same shape a
+// client-go Reflector expects from a kube-apiserver watch: connect once,
+// read a resume token (here, ResourceVersion) off each item, and reconnect
+// with ?resourceVersion= to pick back up after a disconnect.
+// WatchAdmissions提供/admissions/watch端点：一个换行分隔的JSON事件流，使用
+// chunked transfer编码并周期性地轮询环形缓冲区——这是long-poll而非真正的
+// push，但和client-go Reflector对kube-apiserver watch的期望形状是一致的：
+// 连接一次，从每个条目中读出一个恢复token（这里是ResourceVersion），
+// 断线后带着?resourceVersion=重新连接即可接着看。
+func (s *AdmissionServer) WatchAdmissions(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	since := s.events.Latest()
+	if rv := r.URL.Query().Get("resourceVersion"); rv != "" {
+		parsed, err := strconv.ParseUint(rv, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid resourceVersion: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+
+	ctx := r.Context()
+	ticker := time.NewTicker(*admissionWatchPollInterval)
+	defer ticker.Stop()
+	for {
+		for _, event := range s.events.Since(since) {
+			if err := encoder.Encode(event); err != nil {
+				klog.V(4).InfoS("admissions watch client disconnected", "err", err)
+				return
+			}
+			since = event.ResourceVersion
+		}
+		flusher.Flush()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// eventDetailKey is the context.Context key a ResourceHandler uses to enrich
+// the AdmissionEvent admit() records for the request it's currently serving.
+// Only pod admissions populate it today - see admissionEventDetail.
+type eventDetailKey struct{}
+
+// admissionEventDetail is optionally filled in by a ResourceHandler.GetPatches
+// implementation (via the ctx it's given) with information admit() itself
+// has no way to compute, since it only sees patches and an error.
+// admissionEventDetail由ResourceHandler.GetPatches的实现（通过它被传入的ctx）
+// 选择性地填写，其中包含了admit()自身无法计算出的信息，因为admit()本身只能
+// 看到patches和一个error。
+type admissionEventDetail struct {
+	vpaName string
+	summary string
+}
+
+// withEventDetail returns a child of ctx carrying a fresh admissionEventDetail
+// for the handler to fill in, along with that same detail for admit() to
+// read back once GetPatches returns.
+func withEventDetail(ctx context.Context) (context.Context, *admissionEventDetail) {
+	detail := &admissionEventDetail{}
+	return context.WithValue(ctx, eventDetailKey{}, detail), detail
+}
+
+// eventDetailFrom returns the admissionEventDetail stashed in ctx by
+// withEventDetail, or nil if ctx doesn't carry one (e.g. it wasn't built by
+// admit() - callers must handle a nil result).
+func eventDetailFrom(ctx context.Context) *admissionEventDetail {
+	detail, _ := ctx.Value(eventDetailKey{}).(*admissionEventDetail)
+	return detail
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/admission-controller/logic/recommenderpb"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	"k8s.io/klog/v2"
+)
+
+// grpcRecommendationProvider is the --recommendation-provider=grpc
+// RecommendationProvider. It always consults builtin first for VPA
+// matching, the cached recommendation and UpdateMode - none of which an
+// external provider has any way to compute on its own - then gives an
+// external gRPC service a short window to return an improved
+// recommendation for the same pod, falling back to builtin's answer
+// untouched if that call errors or doesn't complete within timeout.
+// grpcRecommendationProvider是--recommendation-provider=grpc所对应的
+// RecommendationProvider。它总是先向builtin请求VPA匹配结果、已缓存的
+// recommendation以及UpdateMode——这些都不是外部provider能够自行计算出的——
+// 然后给外部gRPC服务一个很短的时间窗口，为同一个pod返回一个改进过的
+// recommendation；如果该调用出错或者没能在timeout内完成，就原样回退到
+// builtin给出的结果。
+type grpcRecommendationProvider struct {
+	builtin RecommendationProvider
+	client  recommenderpb.RecommenderClient
+	timeout time.Duration
+}
+
+// NewGRPCRecommendationProvider constructs a RecommendationProvider that
+// calls client for each pod admission, falling back to builtin (typically
+// the provider NewRecommendationProvider returns) whenever client doesn't
+// answer within timeout.
+func NewGRPCRecommendationProvider(client recommenderpb.RecommenderClient, timeout time.Duration, builtin RecommendationProvider) RecommendationProvider {
+	return &grpcRecommendationProvider{
+		builtin: builtin,
+		client:  client,
+		timeout: timeout,
+	}
+}
+
+// GetContainersResourcesForPod implements RecommendationProvider.
+func (p *grpcRecommendationProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error) {
+	resources, annotations, vpaName, updateMode, err := p.builtin.GetContainersResourcesForPod(pod)
+	if err != nil || vpaName == "" {
+		// No matching VPA (or a hard error from builtin) - there is nothing
+		// for the external provider to improve on.
+		return resources, annotations, vpaName, updateMode, err
+	}
+
+	podSpecJSON, marshalErr := json.Marshal(pod.Spec)
+	if marshalErr != nil {
+		klog.V(2).InfoS("cannot marshal pod spec for external recommendation provider, using cached VPA recommendation", "pod", pod.Name, "err", marshalErr)
+		return resources, annotations, vpaName, updateMode, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	resp, grpcErr := p.client.GetRecommendation(ctx, &recommenderpb.GetRecommendationRequest{
+		PodSpec: &recommenderpb.PodSpec{Raw: podSpecJSON},
+		TargetRef: &recommenderpb.TargetRef{
+			Kind:      "VerticalPodAutoscaler",
+			Name:      vpaName,
+			Namespace: pod.Namespace,
+		},
+	})
+	if grpcErr != nil {
+		// 如果调用超时或失败，控制器将采用缓存在VPA对象中的资源建议
+		// (resources, already computed above by builtin).
+		klog.V(2).InfoS("external recommendation provider call failed, falling back to cached VPA recommendation", "pod", pod.Name, "err", grpcErr)
+		return resources, annotations, vpaName, updateMode, nil
+	}
+	return overrideWithProtoRecommendation(resources, pod, resp), annotations, vpaName, updateMode, nil
+}
+
+// ApplyInPlaceIfPossible implements RecommendationProvider by delegating
+// straight to builtin - the external provider only ever improves on the
+// recommendation returned by GetContainersResourcesForPod, it has no
+// separate opinion on how that recommendation gets applied to a running pod.
+func (p *grpcRecommendationProvider) ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error {
+	return p.builtin.ApplyInPlaceIfPossible(pod, resources)
+}
+
+// overrideWithProtoRecommendation replaces the entries of resources - 1-1
+// with pod.Spec.Containers, the same ordering GetContainersResourcesForPod's
+// caller already relies on - with whatever resp has an opinion about,
+// leaving every container resp is silent on at its builtin value.
+func overrideWithProtoRecommendation(resources []vpa_api_util.ContainerResources, pod *core.Pod, resp *recommenderpb.ResourceRecommendation) []vpa_api_util.ContainerResources {
+	byName := make(map[string]*recommenderpb.ContainerResources, len(resp.Containers))
+	for _, container := range resp.Containers {
+		byName[container.ContainerName] = container
+	}
+	for i, container := range pod.Spec.Containers {
+		override, ok := byName[container.Name]
+		if !ok {
+			continue
+		}
+		if requests := resourceListFromProto(override.Requests); requests != nil {
+			resources[i].Requests = requests
+		}
+		if limits := resourceListFromProto(override.Limits); limits != nil {
+			resources[i].Limits = limits
+		}
+	}
+	return resources
+}
+
+// resourceListFromProto parses the string-quantity map a ContainerResources
+// proto message carries back into a core.ResourceList, skipping (and
+// logging) any entry that isn't a valid resource.Quantity rather than
+// failing the whole recommendation over one bad value.
+func resourceListFromProto(values map[string]string) core.ResourceList {
+	if len(values) == 0 {
+		return nil
+	}
+	list := make(core.ResourceList, len(values))
+	for name, value := range values {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			klog.V(2).InfoS("external recommendation provider sent an unparsable quantity, ignoring", "quantity", value, "resource", name)
+			continue
+		}
+		list[core.ResourceName(name)] = quantity
+	}
+	return list
+}
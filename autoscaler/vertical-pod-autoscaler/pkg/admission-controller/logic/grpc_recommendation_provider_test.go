@@ -0,0 +1,205 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/admission-controller/logic/recommenderpb"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+// stubBuiltinProvider is a RecommendationProvider whose answer is entirely
+// fixed ahead of time, so tests can exercise grpcRecommendationProvider in
+// isolation without a real VPA lister/limit range calculator behind it.
+type stubBuiltinProvider struct {
+	resources   []vpa_api_util.ContainerResources
+	annotations vpa_api_util.ContainerToAnnotationsMap
+	vpaName     string
+	updateMode  vpa_types.UpdateMode
+	err         error
+}
+
+func (s stubBuiltinProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error) {
+	return s.resources, s.annotations, s.vpaName, s.updateMode, s.err
+}
+
+func (s stubBuiltinProvider) ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error {
+	return nil
+}
+
+// stubRecommenderClient is a recommenderpb.RecommenderClient whose response
+// (or error) is fixed ahead of time, in place of an actual gRPC dial.
+type stubRecommenderClient struct {
+	resp *recommenderpb.ResourceRecommendation
+	err  error
+}
+
+func (s stubRecommenderClient) GetRecommendation(ctx context.Context, in *recommenderpb.GetRecommendationRequest, opts ...grpc.CallOption) (*recommenderpb.ResourceRecommendation, error) {
+	return s.resp, s.err
+}
+
+func testPod() *core.Pod {
+	return &core.Pod{
+		Spec: core.PodSpec{
+			Containers: []core.Container{{Name: "main"}},
+		},
+	}
+}
+
+// TestGRPCProviderSkipsExternalCallWithoutAMatchingVPA covers the contract
+// that the external provider is never consulted when builtin found no VPA
+// (or failed outright) - there is nothing meaningful to improve on.
+func TestGRPCProviderSkipsExternalCallWithoutAMatchingVPA(t *testing.T) {
+	builtin := stubBuiltinProvider{vpaName: ""}
+	client := stubRecommenderClient{err: errors.New("should not be called")}
+	provider := NewGRPCRecommendationProvider(client, time.Second, builtin)
+
+	_, _, vpaName, _, err := provider.GetContainersResourcesForPod(testPod())
+	if err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if vpaName != "" {
+		t.Errorf("expected no matching VPA name, got %q", vpaName)
+	}
+}
+
+// TestGRPCProviderOverridesWithExternalRecommendation covers the contract
+// that a successful external response overrides builtin's requests/limits
+// for the containers it has an opinion about, leaving others untouched.
+func TestGRPCProviderOverridesWithExternalRecommendation(t *testing.T) {
+	builtin := stubBuiltinProvider{
+		resources: []vpa_api_util.ContainerResources{
+			{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")}},
+		},
+		vpaName:    "some-vpa",
+		updateMode: vpa_types.UpdateModeAuto,
+	}
+	client := stubRecommenderClient{
+		resp: &recommenderpb.ResourceRecommendation{
+			Containers: []*recommenderpb.ContainerResources{
+				{ContainerName: "main", Requests: map[string]string{"cpu": "250m"}},
+			},
+		},
+	}
+	provider := NewGRPCRecommendationProvider(client, time.Second, builtin)
+
+	resources, _, vpaName, updateMode, err := provider.GetContainersResourcesForPod(testPod())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if vpaName != "some-vpa" || updateMode != vpa_types.UpdateModeAuto {
+		t.Errorf("expected builtin's vpaName/updateMode to pass through unchanged, got %q/%v", vpaName, updateMode)
+	}
+	got := resources[0].Requests[core.ResourceCPU]
+	want := resource.MustParse("250m")
+	if got.Cmp(want) != 0 {
+		t.Errorf("expected external recommendation to override cpu request to 250m, got %v", got.String())
+	}
+}
+
+// TestGRPCProviderFallsBackToBuiltinOnError covers the contract that an
+// external call error leaves builtin's recommendation untouched rather than
+// failing the whole admission.
+func TestGRPCProviderFallsBackToBuiltinOnError(t *testing.T) {
+	builtin := stubBuiltinProvider{
+		resources: []vpa_api_util.ContainerResources{
+			{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")}},
+		},
+		vpaName:    "some-vpa",
+		updateMode: vpa_types.UpdateModeAuto,
+	}
+	client := stubRecommenderClient{err: errors.New("deadline exceeded")}
+	provider := NewGRPCRecommendationProvider(client, time.Second, builtin)
+
+	resources, _, _, _, err := provider.GetContainersResourcesForPod(testPod())
+	if err != nil {
+		t.Fatalf("expected a gRPC error to be swallowed with a fallback, got %v", err)
+	}
+	got := resources[0].Requests[core.ResourceCPU]
+	want := resource.MustParse("100m")
+	if got.Cmp(want) != 0 {
+		t.Errorf("expected builtin's cpu request to survive a failed external call, got %v", got.String())
+	}
+}
+
+// TestGRPCProviderIgnoresUnparsableQuantities covers resourceListFromProto's
+// contract of skipping individual bad entries instead of failing the whole
+// recommendation over one malformed quantity from a misbehaving external
+// provider.
+func TestGRPCProviderIgnoresUnparsableQuantities(t *testing.T) {
+	builtin := stubBuiltinProvider{
+		resources: []vpa_api_util.ContainerResources{
+			{Requests: core.ResourceList{core.ResourceCPU: resource.MustParse("100m")}},
+		},
+		vpaName: "some-vpa",
+	}
+	client := stubRecommenderClient{
+		resp: &recommenderpb.ResourceRecommendation{
+			Containers: []*recommenderpb.ContainerResources{
+				{ContainerName: "main", Requests: map[string]string{"cpu": "not-a-quantity"}},
+			},
+		},
+	}
+	provider := NewGRPCRecommendationProvider(client, time.Second, builtin)
+
+	resources, _, _, _, err := provider.GetContainersResourcesForPod(testPod())
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	got := resources[0].Requests[core.ResourceCPU]
+	want := resource.MustParse("100m")
+	if got.Cmp(want) != 0 {
+		t.Errorf("expected builtin's cpu request to survive an unparsable override, got %v", got.String())
+	}
+}
+
+// TestGRPCProviderDelegatesApplyInPlace covers that ApplyInPlaceIfPossible is
+// a pure passthrough to builtin.
+func TestGRPCProviderDelegatesApplyInPlace(t *testing.T) {
+	applied := false
+	builtin := applyTrackingProvider{applied: &applied}
+	provider := NewGRPCRecommendationProvider(stubRecommenderClient{}, time.Second, builtin)
+
+	if err := provider.ApplyInPlaceIfPossible(testPod(), nil); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !applied {
+		t.Errorf("expected ApplyInPlaceIfPossible to delegate to builtin")
+	}
+}
+
+type applyTrackingProvider struct {
+	applied *bool
+}
+
+func (p applyTrackingProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error) {
+	return nil, nil, "", "", nil
+}
+
+func (p applyTrackingProvider) ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error {
+	*p.applied = true
+	return nil
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
+)
+
+// ResourceHandler computes the JSON patches for one kind of admitted object
+// (pods, VPAs, or - via third-party registration - any other workload kind
+// operators want the webhook to mutate/validate). AdmissionServer.admit no
+// longer hard-codes the pod/VPA special cases; it dispatches to whichever
+// registered ResourceHandler matches the incoming request.
+// ResourceHandler负责为一种被admit的对象类型（pods、VPA，或者由第三方注册的任意
+// 其他运营商希望webhook进行mutate/validate的workload类型）计算JSON patches。
+// AdmissionServer.admit不再硬编码pod/VPA的特殊处理，而是把请求分派给匹配该
+// 请求的已注册ResourceHandler。
+type ResourceHandler interface {
+	// GroupVersionResource or GroupResource this handler admits. Exactly one
+	// of the two should be non-zero; GroupVersionResource is matched first.
+	GroupVersionResource() metav1.GroupVersionResource
+	GroupResource() metav1.GroupResource
+	// AdmissionResource is the label reported to the admission metrics for
+	// requests this handler serves.
+	AdmissionResource() metrics_admission.AdmissionResource
+	// GetPatches computes the JSON patches (or a validation error) for raw,
+	// the raw object being admitted in namespace under operation.
+	GetPatches(ctx context.Context, raw []byte, namespace string, operation admissionv1.Operation) ([]patchRecord, error)
+	// DisallowIncorrectObjects reports whether admit() should reject the
+	// request outright when GetPatches returns an error, rather than merely
+	// logging it and letting the object through unpatched. VPA objects have
+	// no CRD OpenAPI schema strict enough to catch every invalid
+	// ResourcePolicy/UpdatePolicy combination, so this handler's validation
+	// is the last line of defense and must block admission; pods have no
+	// equivalent invariant for the webhook to enforce, so a patch-computation
+	// error there is logged but not treated as a rejection.
+	// DisallowIncorrectObjects表示当GetPatches返回错误时，admit()是否应该直接
+	// 拒绝该请求，而不仅仅是记录错误并放行未打patch的对象。VPA对象没有足够
+	// 严格的CRD OpenAPI schema来捕获每一种非法的ResourcePolicy/UpdatePolicy
+	// 组合，所以这个handler的校验是最后一道防线，必须阻止其准入；pod则没有
+	// 与之对应的、需要webhook强制执行的不变量，因此pod的patch计算错误只会被
+	// 记录，而不会被当作拒绝处理。
+	DisallowIncorrectObjects() bool
+}
+
+// ResourceHandlerFactory builds a ResourceHandler bound to a particular
+// AdmissionServer instance. Factories, rather than handlers, are what get
+// registered globally, since a ResourceHandler typically needs access to the
+// server's recommendationProvider/preprocessors/limitsChecker.
+type ResourceHandlerFactory func(s *AdmissionServer) ResourceHandler
+
+// handlerFactories is populated by RegisterResourceHandler, in registration
+// order; the pod and VPA handlers register themselves from this package's
+// init(), keeping their current precedence (pod before VPA).
+var handlerFactories []ResourceHandlerFactory
+
+// RegisterResourceHandler adds factory to the registry consulted when
+// building an AdmissionServer's handler list. Intended to be called from an
+// init() function, mirroring how other pluggable registries in this
+// repository (e.g. pkg/recommender/input/storage) are populated.
+// RegisterResourceHandler将factory加入到构建AdmissionServer处理器列表时所查询的
+// registry中。预期在init()函数中被调用，与本仓库中其他可插拔registry（例如
+// pkg/recommender/input/storage）的填充方式一致。
+func RegisterResourceHandler(factory ResourceHandlerFactory) {
+	handlerFactories = append(handlerFactories, factory)
+}
+
+// buildResourceHandlers instantiates every registered factory against s.
+func buildResourceHandlers(s *AdmissionServer) []ResourceHandler {
+	handlers := make([]ResourceHandler, 0, len(handlerFactories))
+	for _, factory := range handlerFactories {
+		handlers = append(handlers, factory(s))
+	}
+	return handlers
+}
+
+// matchResourceHandler returns the first handler whose GroupVersionResource
+// or GroupResource matches resource/groupResource.
+func matchResourceHandler(handlers []ResourceHandler, resource metav1.GroupVersionResource, groupResource metav1.GroupResource) ResourceHandler {
+	for _, handler := range handlers {
+		if gvr := handler.GroupVersionResource(); gvr != (metav1.GroupVersionResource{}) && gvr == resource {
+			return handler
+		}
+		if gr := handler.GroupResource(); gr != (metav1.GroupResource{}) && gr == groupResource {
+			return handler
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
+)
+
+func init() {
+	RegisterResourceHandler(func(s *AdmissionServer) ResourceHandler {
+		return &podResourceHandler{server: s}
+	})
+}
+
+// podResourceHandler is the built-in ResourceHandler for core/v1 Pods,
+// delegating to the existing recommendationProvider-backed patch logic.
+type podResourceHandler struct {
+	server *AdmissionServer
+}
+
+func (h *podResourceHandler) GroupVersionResource() metav1.GroupVersionResource {
+	return metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
+}
+
+func (h *podResourceHandler) GroupResource() metav1.GroupResource {
+	return metav1.GroupResource{}
+}
+
+func (h *podResourceHandler) AdmissionResource() metrics_admission.AdmissionResource {
+	return metrics_admission.Pod
+}
+
+func (h *podResourceHandler) GetPatches(ctx context.Context, raw []byte, namespace string, operation admissionv1.Operation) ([]patchRecord, error) {
+	return h.server.getPatchesForPodResourceRequest(ctx, raw, namespace, operation)
+}
+
+// DisallowIncorrectObjects implements ResourceHandler - pods have no
+// equivalent invariant for the webhook to enforce, so a patch-computation
+// error is logged by admit() but doesn't block the pod from being admitted.
+func (h *podResourceHandler) DisallowIncorrectObjects() bool {
+	return false
+}
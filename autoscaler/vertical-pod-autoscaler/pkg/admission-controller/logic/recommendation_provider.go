@@ -17,22 +17,32 @@ limitations under the License.
 package logic
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 
 	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	apitypes "k8s.io/apimachinery/pkg/types"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
 	vpa_lister "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/limitrange"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 )
 
-// RecommendationProvider gets current recommendation, annotations and vpaName for the given pod.
-// RecommendationProvider获取给定pod的当前recommendation, annotations and vpaName。
+// RecommendationProvider gets current recommendation, annotations, vpaName and
+// UpdateMode for the given pod.
+// RecommendationProvider获取给定pod的当前recommendation, annotations, vpaName和UpdateMode。
 type RecommendationProvider interface {
-	GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, error)
+	GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error)
+	// ApplyInPlaceIfPossible patches an already-running pod's containers via
+	// the pods/resize subresource, instead of relying on the caller to evict
+	// and recreate it. See the method doc below for details.
+	ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error
 }
 
 type recommendationProvider struct {
@@ -40,20 +50,88 @@ type recommendationProvider struct {
 	recommendationProcessor vpa_api_util.RecommendationProcessor
 	selectorFetcher         target.VpaTargetSelectorFetcher
 	vpaLister               vpa_lister.VerticalPodAutoscalerLister
+	kubeClient              kube_client.Interface
 }
 
 // NewRecommendationProvider constructs the recommendation provider that list VPAs and can be used to determine recommendations for pods.
+// kubeClient is used only by ApplyInPlaceIfPossible, to issue the resize
+// subresource patch; it may be nil for callers that never exercise that path.
 // NewRecommendationProvider构造列出VPA的recommendation提供者，可用于确定Pod的recommendation。
+// kubeClient仅由ApplyInPlaceIfPossible使用，用于发起resize子资源的patch；对于
+// 从不使用该路径的调用者，它可以为nil。
 func NewRecommendationProvider(calculator limitrange.LimitRangeCalculator, recommendationProcessor vpa_api_util.RecommendationProcessor,
-	selectorFetcher target.VpaTargetSelectorFetcher, vpaLister vpa_lister.VerticalPodAutoscalerLister) *recommendationProvider {
+	selectorFetcher target.VpaTargetSelectorFetcher, vpaLister vpa_lister.VerticalPodAutoscalerLister, kubeClient kube_client.Interface) *recommendationProvider {
 	return &recommendationProvider{
 		limitsRangeCalculator:   calculator,
 		recommendationProcessor: recommendationProcessor,
 		selectorFetcher:         selectorFetcher,
 		vpaLister:               vpaLister,
+		kubeClient:              kubeClient,
 	}
 }
 
+type containerResizePatch struct {
+	Name      string                    `json:"name"`
+	Resources core.ResourceRequirements `json:"resources"`
+}
+
+type podResizePatch struct {
+	Spec struct {
+		Containers []containerResizePatch `json:"containers"`
+	} `json:"spec"`
+}
+
+// ApplyInPlaceIfPossible patches pod's containers via the pods/resize
+// subresource, applying resources without restarting it. It is the in-place
+// counterpart to the JSONPatch mutation GetContainersResourcesForPod's result
+// is normally turned into at pod creation time: a caller that routes an
+// already-running pod's update through the resize path instead of eviction
+// (see actionInPlace/resolveUpdateAction in
+// pkg/updater/priority.UpdatePriorityCalculator) calls this once it has
+// decided the recommendation fits within every changed container's
+// resizePolicy. Returns an error if the resize subresource rejects the
+// patch (e.g. Infeasible); the caller should fall back to eviction in that
+// case.
+// ApplyInPlaceIfPossible通过pods/resize子资源patch pod的容器，在不重启的情况下
+// 应用resources。它是GetContainersResourcesForPod的结果在pod创建时被转换成的
+// JSONPatch变更的原地对应版本：调用者如果把一个已经在运行的pod的更新导向resize
+// 路径而不是驱逐（见pkg/updater/priority.UpdatePriorityCalculator中的
+// actionInPlace/resolveUpdateAction），在确认recommendation符合每个发生变化
+// 容器的resizePolicy之后，调用这个方法。如果resize子资源拒绝了这次patch（比如
+// Infeasible），会返回错误；此时调用者应当回退到驱逐路径。
+func (p *recommendationProvider) ApplyInPlaceIfPossible(pod *core.Pod, resources []vpa_api_util.ContainerResources) error {
+	if p.kubeClient == nil {
+		return fmt.Errorf("no kubeClient configured, cannot resize pod %s/%s in place", pod.Namespace, pod.Name)
+	}
+	patch := podResizePatch{}
+	for i, container := range pod.Spec.Containers {
+		if i >= len(resources) || resources[i].Requests == nil {
+			continue
+		}
+		patch.Spec.Containers = append(patch.Spec.Containers, containerResizePatch{
+			Name: container.Name,
+			Resources: core.ResourceRequirements{
+				Requests: resources[i].Requests,
+				Limits:   resources[i].Limits,
+			},
+		})
+	}
+	if len(patch.Spec.Containers) == 0 {
+		return nil
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = p.kubeClient.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, apitypes.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "resize")
+	if err != nil {
+		return fmt.Errorf("failed to patch resize subresource for pod %s/%s: %v", pod.Namespace, pod.Name, err)
+	}
+	klog.V(2).Infof("resized pod %s/%s in place", pod.Namespace, pod.Name)
+	return nil
+}
+
 // GetContainersResources returns the recommended resources for each container in the given pod in the same order they are specified in the pod.Spec.
 // GetContainersResources按照给定pod.Spec中指定的顺序返回给定pod中每个容器的recommended资源。
 func GetContainersResources(pod *core.Pod, podRecommendation vpa_types.RecommendedPodResources, limitRange *core.LimitRangeItem,
@@ -120,18 +198,19 @@ func (p *recommendationProvider) getMatchingVPA(pod *core.Pod) *vpa_types.Vertic
 	return nil
 }
 
-// GetContainersResourcesForPod returns recommended request for a given pod, annotations and name of controlling VPA.
+// GetContainersResourcesForPod returns recommended request for a given pod, annotations, name of controlling VPA and its UpdateMode.
 // The returned slice corresponds 1-1 to containers in the Pod.
 // 更新对于指定pod的recommended推荐需求
-func (p *recommendationProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, error) {
+func (p *recommendationProvider) GetContainersResourcesForPod(pod *core.Pod) ([]vpa_api_util.ContainerResources, vpa_api_util.ContainerToAnnotationsMap, string, vpa_types.UpdateMode, error) {
 	klog.V(2).Infof("updating requirements for pod %s.", pod.Name)
 	vpaConfig := p.getMatchingVPA(pod)
 	// 一. 获取指定的vpa(创建时间最早的)，该vpa更新状态未设置为off并且和截取到的pod创建信息匹配
 	if vpaConfig == nil {
 		klog.V(2).Infof("no matching VPA found for pod %s", pod.Name)
-		return nil, nil, "", nil
+		return nil, nil, "", vpa_types.UpdateModeOff, nil
 		// 若不匹配，则返回无。
 	}
+	updateMode := vpa_api_util.GetUpdateMode(vpaConfig)
 
 	var annotations vpa_api_util.ContainerToAnnotationsMap
 	recommendedPodResources := &vpa_types.RecommendedPodResources{}
@@ -142,15 +221,44 @@ func (p *recommendationProvider) GetContainersResourcesForPod(pod *core.Pod) ([]
 		// 二. 后处理recommendation
 		if err != nil {
 			klog.V(2).Infof("cannot process recommendation for pod %s", pod.Name)
-			return nil, annotations, vpaConfig.Name, err
+			return nil, annotations, vpaConfig.Name, updateMode, err
 		}
 	}
-	containerLimitRange, err := p.limitsRangeCalculator.GetContainerLimitRangeItem(pod.Namespace)
-	// 三. 获取容器运行时的限制范围
+	bounds, err := p.limitsRangeCalculator.GetNamespaceResourceBounds(pod.Namespace)
+	// 三. 获取容器运行时的限制范围，以及该命名空间ResourceQuota剩余的headroom
 	if err != nil {
-		return nil, nil, "", fmt.Errorf("error getting containerLimitRange: %s", err)
+		return nil, nil, "", updateMode, fmt.Errorf("error getting namespace resource bounds: %s", err)
 	}
-	containerResources := GetContainersResources(pod, *recommendedPodResources, containerLimitRange, annotations)
+	containerResources := GetContainersResources(pod, *recommendedPodResources, bounds.Container, annotations)
 	// 四. 获取容器资源，返回的resources保存了每个容器对内存和cpu的限制信息
-	return containerResources, annotations, vpaConfig.Name, nil
+	capToQuotaHeadroom(containerResources, bounds.Quota)
+	// 五. 再用ResourceQuota的headroom做一次更严格的封顶，避免LimitRange允许但
+	// ResourceQuota会拒绝的情况
+	return containerResources, annotations, vpaConfig.Name, updateMode, nil
+}
+
+// capToQuotaHeadroom clamps every container's Requests/Limits down to quota's
+// Max, if quota is non-nil. The namespace's ResourceQuota headroom is the
+// final, tightest cap a recommendation must respect - LimitRange alone can
+// permit more than ResourceQuota has left to give.
+// capToQuotaHeadroom会把每个容器的Requests/Limits收紧到quota的Max以内（如果
+// quota不为nil）。该命名空间ResourceQuota的headroom是recommendation必须遵守
+// 的最终、最严格的上限——单靠LimitRange可能允许的值会超过ResourceQuota剩余的
+// 额度。
+func capToQuotaHeadroom(resources []vpa_api_util.ContainerResources, quota *core.LimitRangeItem) {
+	if quota == nil {
+		return
+	}
+	for i := range resources {
+		capResourceList(resources[i].Requests, quota.Max)
+		capResourceList(resources[i].Limits, quota.Max)
+	}
+}
+
+func capResourceList(list, max core.ResourceList) {
+	for name, quantity := range list {
+		if maxQuantity, found := max[name]; found && quantity.Cmp(maxQuantity) > 0 {
+			list[name] = maxQuantity
+		}
+	}
 }
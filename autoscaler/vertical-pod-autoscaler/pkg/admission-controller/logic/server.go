@@ -17,34 +17,116 @@ limitations under the License.
 package logic
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strings"
+	"time"
 
-	"k8s.io/api/admission/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	policy_v1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1alpha1"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/limitrange"
 	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
-	"k8s.io/klog"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
 )
 
+// AdmissionPolicyProvider is consulted once per pod admission request to look
+// up the VerticalPodAutoscalerAdmissionPolicy in effect for that pod's
+// namespace, if any. Implemented by pkg/admission-controller/policy against a
+// lister-backed cache kept current by a controller watching the CRD; nil is
+// a valid AdmissionServer dependency and means no policy ever applies (every
+// pod admitted as if no AdmissionPolicy objects existed).
+// AdmissionPolicyProvider会在每次pod admission请求时被查询一次，找出对该pod
+// 所在namespace生效的VerticalPodAutoscalerAdmissionPolicy（如果有的话）。它由
+// pkg/admission-controller/policy包基于一个由controller保持同步的、
+// lister支撑的缓存来实现；nil是AdmissionServer的一个合法依赖，表示永远没有
+// policy生效（就像不存在任何AdmissionPolicy对象一样admit每个pod）。
+type AdmissionPolicyProvider interface {
+	// PolicyFor returns the effective policy spec for namespace, or nil if
+	// none applies.
+	PolicyFor(namespace string) *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec
+}
+
 // AdmissionServer is an admission webhook server that modifies pod resources request based on VPA recommendation
 type AdmissionServer struct {
 	recommendationProvider RecommendationProvider
 	podPreProcessor        PodPreProcessor
 	vpaPreProcessor        VpaPreProcessor
 	limitsChecker          limitrange.LimitRangeCalculator
+	// inPlaceResizeSupported reports whether the API server this admission
+	// controller talks to advertises the pods/resize subresource. Computed
+	// once at startup (see podResizeSubresourceSupported in main.go); when
+	// false, VPAs in InPlace mode fall back to behaving like Recreate, since
+	// there is nothing in-place to resize into.
+	// inPlaceResizeSupported表示该admission controller所连接的API server是否
+	// 支持pods/resize子资源，在启动时计算一次。当为false时，InPlace模式的VPA
+	// 回退为与Recreate相同的行为，因为没有可供原地resize的子资源。
+	inPlaceResizeSupported bool
+	// inPlaceResizeChangeThreshold is the minimum relative change, for every
+	// resource, below which a container is left out of an in-place resize
+	// patch entirely. Only consulted for in-place resize, never for pod
+	// creation, so a pod with several containers - only some of which
+	// actually need a change - only has those containers resized.
+	// inPlaceResizeChangeThreshold是每个资源最小的相对变化量，低于这个值的容器
+	// 会被完全排除在in-place resize的patch之外。只在in-place resize时才会被
+	// 参考，pod创建时永远不会用到，这样一个有若干容器、只有部分容器真正需要
+	// 变化的pod，就只会resize那些真正需要变化的容器。
+	inPlaceResizeChangeThreshold float64
+	// policyProvider looks up the VerticalPodAutoscalerAdmissionPolicy in
+	// effect for an admitted pod's namespace, if any. May be nil, in which
+	// case no policy is ever consulted - see AdmissionPolicyProvider.
+	// policyProvider查找对被admit的pod所在namespace生效的
+	// VerticalPodAutoscalerAdmissionPolicy（如果有的话）。可以为nil，此时
+	// 永远不会查询任何policy——见AdmissionPolicyProvider。
+	policyProvider AdmissionPolicyProvider
+	// eventRecorder, if non-nil, is used to emit a Kubernetes Event on every
+	// rejected admission, so `kubectl describe` on the object that was
+	// denied shows why - the same visibility the scheduler already gives
+	// operators for failed scheduling decisions.
+	// eventRecorder如果非nil，会在每次admission被拒绝时发出一个Kubernetes
+	// Event，这样对被拒绝的对象执行`kubectl describe`就能看到拒绝的原因——
+	// 这和scheduler早已为调度失败提供的可见性是一样的。
+	eventRecorder record.EventRecorder
+	// events is the bounded ring buffer backing the /admissions/watch debug
+	// endpoint - see AdmissionEvent and admissionEventRing.
+	// events是支撑/admissions/watch调试端点的有界环形缓冲区——见AdmissionEvent
+	// 和admissionEventRing。
+	events *admissionEventRing
+	// handlers holds one ResourceHandler per kind this server admits, built
+	// from every factory registered with RegisterResourceHandler - the built
+	// in pod/VPA handlers plus whatever operators have compiled in.
+	// handlers为该server所admit的每一种kind保存一个ResourceHandler，
+	// 由所有通过RegisterResourceHandler注册的factory构建而来——既包括内置的
+	// pod/VPA处理器，也包括运营商自行编译进来的处理器。
+	handlers []ResourceHandler
+	// ctx is the server's own lifecycle context, independent of any single
+	// request's context. main cancels it once this replica's HTTP server
+	// starts draining - e.g. after losing a leader election - so Serve can
+	// reject new work immediately instead of racing the shutdown.
+	// ctx是server自身生命周期的context，与任何单次请求的context无关。main
+	// 会在该副本的HTTP server开始排空连接时（例如失去leader election之后）
+	// 取消它，这样Serve就能立刻拒绝新的请求，而不是和shutdown过程发生竞争。
+	ctx context.Context
 }
 
-// NewAdmissionServer constructs new AdmissionServer
-func NewAdmissionServer(recommendationProvider RecommendationProvider, podPreProcessor PodPreProcessor, vpaPreProcessor VpaPreProcessor, limitsChecker limitrange.LimitRangeCalculator) *AdmissionServer {
-	return &AdmissionServer{recommendationProvider, podPreProcessor, vpaPreProcessor, limitsChecker}
+// NewAdmissionServer constructs new AdmissionServer. ctx is the server's
+// lifecycle context - see AdmissionServer.ctx. policyProvider and
+// eventRecorder may both be nil - see AdmissionServer.policyProvider and
+// AdmissionServer.eventRecorder.
+func NewAdmissionServer(recommendationProvider RecommendationProvider, podPreProcessor PodPreProcessor, vpaPreProcessor VpaPreProcessor, limitsChecker limitrange.LimitRangeCalculator, inPlaceResizeSupported bool, inPlaceResizeChangeThreshold float64, ctx context.Context, policyProvider AdmissionPolicyProvider, eventRecorder record.EventRecorder) *AdmissionServer {
+	s := &AdmissionServer{recommendationProvider, podPreProcessor, vpaPreProcessor, limitsChecker, inPlaceResizeSupported, inPlaceResizeChangeThreshold, policyProvider, eventRecorder, newAdmissionEventRing(*admissionEventRingSize), nil, ctx}
+	s.handlers = buildResourceHandlers(s)
+	return s
 }
 
 type patchRecord struct {
@@ -53,7 +135,7 @@ type patchRecord struct {
 	Value interface{} `json:"value"`
 }
 
-func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace string) ([]patchRecord, error) {
+func (s *AdmissionServer) getPatchesForPodResourceRequest(ctx context.Context, raw []byte, namespace string, operation admissionv1.Operation) ([]patchRecord, error) {
 	pod := v1.Pod{}
 	if err := json.Unmarshal(raw, &pod); err != nil {
 		return nil, err
@@ -62,12 +144,28 @@ func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace
 		pod.Name = pod.GenerateName + "%"
 		pod.Namespace = namespace
 	}
-	klog.V(4).Infof("Admitting pod %v", pod.ObjectMeta)
-	containersResources, annotationsPerContainer, vpaName, err := s.recommendationProvider.GetContainersResourcesForPod(&pod)
+	klog.V(4).InfoS("Admitting pod", "pod", pod.Name, "namespace", pod.Namespace)
+	containersResources, annotationsPerContainer, vpaName, updateMode, err := s.recommendationProvider.GetContainersResourcesForPod(&pod)
 	// 获取pod中的容器资源
 	if err != nil {
 		return nil, err
 	}
+	if detail := eventDetailFrom(ctx); detail != nil {
+		detail.vpaName = vpaName
+	}
+
+	inPlaceResize := operation != admissionv1.Create
+	if inPlaceResize && (updateMode != vpa_types.UpdateModeInPlace || !s.inPlaceResizeSupported) {
+		// Outside of pod creation we only ever touch a pod's resources when
+		// its VPA opted into InPlace and the cluster actually supports the
+		// resize subresource; otherwise the Updater is left to evict under
+		// Recreate, same as before InPlace existed.
+		// 在pod创建之外，我们只有在其VPA选择了InPlace模式，并且集群确实支持
+		// resize子资源时，才会去改动pod的资源；否则就像InPlace出现之前一样，
+		// 交给Updater在Recreate模式下驱逐处理。
+		return nil, nil
+	}
+
 	pod, err = s.podPreProcessor.Process(pod)
 	// 预处理
 	if err != nil {
@@ -77,27 +175,56 @@ func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace
 		annotationsPerContainer = vpa_api_util.ContainerToAnnotationsMap{}
 	}
 
+	var policy *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec
+	if s.policyProvider != nil {
+		policy = s.policyProvider.PolicyFor(pod.Namespace)
+	}
+	excludedContainers := excludedContainerSet(policy)
+
 	patches := []patchRecord{}
 	updatesAnnotation := []string{}
 	for i, containerResources := range containersResources {
+		if inPlaceResize && s.belowInPlaceResizeThreshold(pod.Spec.Containers[i], containerResources) {
+			klog.V(4).InfoS("skipping in-place resize, container below change threshold", "pod", pod.Name, "container", pod.Spec.Containers[i].Name)
+			continue
+		}
+		if excludedContainers[pod.Spec.Containers[i].Name] {
+			klog.V(4).InfoS("skipping container excluded by admission policy", "pod", pod.Name, "container", pod.Spec.Containers[i].Name)
+			continue
+		}
+		clampToPolicyOverlay(containerResources, policy)
 		newPatches, newUpdatesAnnotation := s.getContainerPatch(pod, i, annotationsPerContainer, containerResources)
 		// 对容器中一些信息进行填补
 		patches = append(patches, newPatches...)
 		updatesAnnotation = append(updatesAnnotation, newUpdatesAnnotation)
 		// 获取填补的patches和更新的注释updatesAnnotation
 	}
+	if policy != nil && policy.DryRun && len(patches) > 0 {
+		// The policy wants this namespace observed, not mutated - report what
+		// would have happened and return no patches at all.
+		// 这个namespace只想被观测，而不是被mutate——报告本来会发生的变化，
+		// 然后不返回任何patch。
+		klog.V(4).InfoS("suppressing patches, admission policy is in dry-run mode", "pod", pod.Name, "namespace", pod.Namespace, "patches", len(patches))
+		metrics_admission.ObservePolicyDryRun(metrics_admission.Pod)
+		return nil, nil
+	}
 	if len(updatesAnnotation) > 0 {
 		// 如果更新了注释，说明pod资源进行了更新
+		annotationKey := "vpaUpdates"
 		vpaAnnotationValue := fmt.Sprintf("Pod resources updated by %s: %s", vpaName, strings.Join(updatesAnnotation, "; "))
+		if inPlaceResize {
+			annotationKey = "vpa-inplace-resize"
+			vpaAnnotationValue = fmt.Sprintf("Pod resources resized in-place by %s: %s", vpaName, strings.Join(updatesAnnotation, "; "))
+		}
 		if pod.Annotations == nil {
 			patches = append(patches, patchRecord{
 				Op:    "add",
 				Path:  "/metadata/annotations",
-				Value: map[string]string{"vpaUpdates": vpaAnnotationValue}})
+				Value: map[string]string{annotationKey: vpaAnnotationValue}})
 		} else {
 			patches = append(patches, patchRecord{
 				Op:    "add",
-				Path:  "/metadata/annotations/vpaUpdates",
+				Path:  "/metadata/annotations/" + annotationKey,
 				Value: vpaAnnotationValue})
 		}
 		// 对pod的注释变量进行赋值
@@ -105,6 +232,77 @@ func (s *AdmissionServer) getPatchesForPodResourceRequest(raw []byte, namespace
 	return patches, nil
 }
 
+// belowInPlaceResizeThreshold reports whether every resource in
+// containerResources.Requests is within s.inPlaceResizeChangeThreshold of
+// container's current request, meaning the container can be left out of an
+// in-place resize patch entirely. A container with no recommended requests
+// at all is never considered below threshold - that happens independently
+// via containersResources - so this only returns true once at least one
+// resource was actually compared.
+// belowInPlaceResizeThreshold表示containerResources.Requests里的每一个资源，
+// 是否都与container当前的request相差不超过s.inPlaceResizeChangeThreshold，
+// 也就是说这个容器可以被完全排除在in-place resize的patch之外。完全没有推荐
+// requests的容器永远不会被认为低于阈值——那是由containersResources单独处理
+// 的——所以只有在至少比较过一个资源之后才会返回true。
+func (s *AdmissionServer) belowInPlaceResizeThreshold(container v1.Container, containerResources vpa_api_util.ContainerResources) bool {
+	compared := false
+	for resourceName, recommended := range containerResources.Requests {
+		current, hasCurrent := container.Resources.Requests[resourceName]
+		if !hasCurrent {
+			return false
+		}
+		request := math.Max(float64(current.MilliValue()), 1.0)
+		diff := math.Abs(request-float64(recommended.MilliValue())) / request
+		if diff >= s.inPlaceResizeChangeThreshold {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
+
+// excludedContainerSet returns policy.ExcludedContainers as a lookup set, or
+// an empty (non-nil, so callers can index it unconditionally) set if policy
+// is nil or sets no exclusions.
+func excludedContainerSet(policy *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec) map[string]bool {
+	set := make(map[string]bool)
+	if policy == nil {
+		return set
+	}
+	for _, name := range policy.ExcludedContainers {
+		set[name] = true
+	}
+	return set
+}
+
+// clampToPolicyOverlay tightens containerResources.Requests/Limits in place
+// so that no resource falls outside policy.MinAllowedOverlay/MaxAllowedOverlay,
+// in addition to whatever bounds the owning VPA's own ResourcePolicy already
+// applied. A nil policy, or one with no overlay set, leaves containerResources
+// untouched.
+// clampToPolicyOverlay会就地收紧containerResources.Requests/Limits，使得任何
+// 资源都不会超出policy.MinAllowedOverlay/MaxAllowedOverlay的范围，这是在其所属
+// VPA自身的ResourcePolicy已经施加的边界之外、额外叠加的一层。policy为nil，或者
+// 没有设置overlay，都不会改动containerResources。
+func clampToPolicyOverlay(containerResources vpa_api_util.ContainerResources, policy *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec) {
+	if policy == nil {
+		return
+	}
+	clampResourceList(containerResources.Requests, policy.MinAllowedOverlay, policy.MaxAllowedOverlay)
+	clampResourceList(containerResources.Limits, policy.MinAllowedOverlay, policy.MaxAllowedOverlay)
+}
+
+func clampResourceList(resources, minAllowed, maxAllowed v1.ResourceList) {
+	for name, quantity := range resources {
+		if min, found := minAllowed[name]; found && quantity.Cmp(min) < 0 {
+			resources[name] = min
+		}
+		if max, found := maxAllowed[name]; found && quantity.Cmp(max) > 0 {
+			resources[name] = max
+		}
+	}
+}
+
 func getPatchInitializingEmptyResources(i int) patchRecord {
 	return patchRecord{
 		Op:    "add",
@@ -177,6 +375,7 @@ var (
 		vpa_types.UpdateModeInitial:  struct{}{},
 		vpa_types.UpdateModeRecreate: struct{}{},
 		vpa_types.UpdateModeAuto:     struct{}{},
+		vpa_types.UpdateModeInPlace:  struct{}{},
 	}
 
 	possibleScalingModes = map[vpa_types.ContainerScalingMode]interface{}{
@@ -223,6 +422,14 @@ func validateVPA(vpa *vpa_types.VerticalPodAutoscaler, isCreate bool) error {
 	return nil
 }
 
+// getPatchesForVPADefaults defaults and validates a VPA object regardless of
+// which recommender(s) spec.Recommenders names - the admission controller is
+// recommender-agnostic and must keep defaulting/validating every VPA the
+// same way, even ones another recommender instance owns.
+// getPatchesForVPADefaults对一个VPA对象进行defaulting和validating，无论
+// spec.Recommenders指定了哪个/哪些recommender——admission controller与
+// recommender无关，必须对每一个VPA都用同样的方式进行defaulting/validating，
+// 即便它由另一个recommender实例负责。
 func (s *AdmissionServer) getPatchesForVPADefaults(raw []byte, isCreate bool) ([]patchRecord, error) {
 	vpa, err := parseVPA(raw)
 	if err != nil {
@@ -254,91 +461,213 @@ func (s *AdmissionServer) getPatchesForVPADefaults(raw []byte, isCreate bool) ([
 	return patches, nil
 }
 
-func (s *AdmissionServer) admit(data []byte) (*v1beta1.AdmissionResponse, metrics_admission.AdmissionStatus, metrics_admission.AdmissionResource) {
+func (s *AdmissionServer) admit(ctx context.Context, data []byte) (response *admissionv1.AdmissionResponse, status metrics_admission.AdmissionStatus, resource metrics_admission.AdmissionResource) {
+	start := time.Now()
 	// we don't block the admission by default, even on unparsable JSON
 	// 默认情况下，即使在无法解析的JSON上，我们也不会阻止访问
-	response := v1beta1.AdmissionResponse{}
+	resp := admissionv1.AdmissionResponse{}
 	// 访问的响应
-	response.Allowed = true
+	resp.Allowed = true
 	// 将响应设为允许状态
+	response = &resp
+	resource = metrics_admission.Unknown
 
-	ar := v1beta1.AdmissionReview{}
+	ar := admissionv1.AdmissionReview{}
 	// admission请求
 	if err := json.Unmarshal(data, &ar); err != nil {
 		// 如果json无法解析，则返回响应和错误信息，metrics_admission.Error, metrics_admission.Unknown两个参数告诉监控系统发生错误
-		klog.Error(err)
-		return &response, metrics_admission.Error, metrics_admission.Unknown
+		klog.ErrorS(err, "Cannot unmarshal admission review request")
+		status = metrics_admission.Error
+		return
 	}
-	// The externalAdmissionHookConfiguration registered via selfRegistration
-	// asks the kube-apiserver only to send admission requests regarding pods & VPA objects.
-	// 请求kube-apiserver，要求其只发送有关pods和vpa对象的admission请求
-	podResource := metav1.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
-	vpaGroupResource := metav1.GroupResource{Group: "autoscaling.k8s.io", Resource: "verticalpodautoscalers"}
+	// admission.k8s.io/v1 requires the response to echo back the request UID.
+	// admission.k8s.io/v1要求响应中回传请求的UID。
+	resp.UID = ar.Request.UID
+
+	dryRun := ar.Request.DryRun != nil && *ar.Request.DryRun
+
+	// Record an AdmissionEvent and, for a rejected admission, a Kubernetes
+	// Event on the involved object, no matter which path below returns.
+	// 无论下面哪条路径返回，都记录一个AdmissionEvent；如果该次admission被
+	// 拒绝，还会在被涉及的对象上记录一个Kubernetes Event。
+	defer func() {
+		s.recordAdmission(ctx, ar, response, status, resource, time.Since(start), dryRun)
+	}()
 
+	select {
+	case <-ctx.Done():
+		klog.ErrorS(ctx.Err(), "Admission request cancelled before processing", "uid", ar.Request.UID)
+		status = metrics_admission.Error
+		return
+	default:
+	}
+
+	if dryRun {
+		// Patches are still computed and returned so a `kubectl --dry-run=server`
+		// caller sees what would change, but we must not record it as if a real
+		// mutation had happened.
+		// 仍然计算并返回patches，使得`kubectl --dry-run=server`的调用者能看到会
+		// 发生的变化，但不能把它当作真正发生过的mutation来记录。
+		klog.V(4).InfoS("Processing dry-run admission request", "uid", ar.Request.UID)
+	}
+	// The externalAdmissionHookConfiguration registered via selfRegistration
+	// asks the kube-apiserver only to send admission requests regarding kinds
+	// one of s.handlers knows how to admit (by default, pods & VPA objects).
+	// 请求kube-apiserver，要求其只发送s.handlers中某个handler能够处理的admission
+	// 请求（默认情况下是pods和vpa对象）
 	var patches []patchRecord
 	var err error
-	resource := metrics_admission.Unknown
+	resource = metrics_admission.Unknown
 
 	admittedGroupResource := metav1.GroupResource{
 		Group:    ar.Request.Resource.Group,
 		Resource: ar.Request.Resource.Resource,
 	}
 
-	if ar.Request.Resource == podResource {
-		// admission请求和从kube-apiserver获取到的pod的admission请求相同
-		patches, err = s.getPatchesForPodResourceRequest(ar.Request.Object.Raw, ar.Request.Namespace)
-		// 从pod的资源请求中获取填补信息patches
-		resource = metrics_admission.Pod
-		// 将资源类型赋值为pod
-	} else if admittedGroupResource == vpaGroupResource {
-		// admission请求和从kube-apiserver获取到的vpa的admission请求相同
-		patches, err = s.getPatchesForVPADefaults(ar.Request.Object.Raw, ar.Request.Operation == v1beta1.Create)
-		// 从vpa的资源请求中获取填补信息patches
-		resource = metrics_admission.Vpa
-		// 将资源类型赋值为vpa
-		// we don't let in problematic VPA objects - late validation
-		if err != nil {
-			status := metav1.Status{}
-			status.Status = "Failure"
-			status.Message = err.Error()
-			response.Result = &status
-			response.Allowed = false
-		}
+	ctx, detail := withEventDetail(ctx)
+	handler := matchResourceHandler(s.handlers, ar.Request.Resource, admittedGroupResource)
+	if handler == nil {
+		// 没有任何handler能够处理该资源，则会输出报错
+		patches, err = nil, fmt.Errorf("expected the resource to be one of the registered resource handlers, got: %v", ar.Request.Resource)
 	} else {
-		patches, err = nil, fmt.Errorf("expected the resource to be one of: %v, %v", podResource, vpaGroupResource)
-		// 如果两个资源都不是，则会输出报错
+		patches, err = handler.GetPatches(ctx, ar.Request.Object.Raw, ar.Request.Namespace, ar.Request.Operation)
+		resource = handler.AdmissionResource()
+		if err != nil && handler.DisallowIncorrectObjects() {
+			// the handler says this error must block admission rather than
+			// just being logged below - 该handler表明此错误必须阻止准入，
+			// 而不只是记录到下面的日志中
+			rejectStatus := metav1.Status{}
+			rejectStatus.Status = "Failure"
+			rejectStatus.Message = err.Error()
+			resp.Result = &rejectStatus
+			resp.Allowed = false
+			detail.summary = err.Error()
+		}
 	}
 
 	if err != nil {
-		klog.Error(err)
-		return &response, metrics_admission.Error, resource
+		klog.ErrorS(err, "Cannot compute patches for admission request", "resource", resource)
+		status = metrics_admission.Error
+		return
+	}
+	if detail.summary == "" {
+		detail.summary = summarizePatches(patches)
 	}
 
 	if len(patches) > 0 {
 		patch, err := json.Marshal(patches)
 		// 解析patches
 		if err != nil {
-			klog.Errorf("Cannot marshal the patch %v: %v", patches, err)
-			return &response, metrics_admission.Error, resource
+			klog.ErrorS(err, "Cannot marshal the patch", "patches", patches, "resource", resource)
+			status = metrics_admission.Error
+			return
 		}
-		patchType := v1beta1.PatchTypeJSONPatch
-		response.PatchType = &patchType
-		response.Patch = patch
+		patchType := admissionv1.PatchTypeJSONPatch
+		resp.PatchType = &patchType
+		resp.Patch = patch
 		// 解析得到的patch赋值给response用于响应
-		klog.V(4).Infof("Sending patches: %v", patches)
+		klog.V(4).InfoS("Sending patches", "patches", patches, "resource", resource)
 	}
 	// 和metrics交互
-	var status metrics_admission.AdmissionStatus
 	if len(patches) > 0 {
 		status = metrics_admission.Applied
 	} else {
 		status = metrics_admission.Skipped
 	}
-	if resource == metrics_admission.Pod {
+	if resource == metrics_admission.Pod && !dryRun {
 		metrics_admission.OnAdmittedPod(status == metrics_admission.Applied)
 	}
 
-	return &response, status, resource
+	return
+}
+
+// summarizePatches renders patches as a short human-readable string for the
+// AdmissionEvent stream - the same shape as the "updatesAnnotation" text
+// already written onto patched pods, just without the per-pod annotation.
+func summarizePatches(patches []patchRecord) string {
+	if len(patches) == 0 {
+		return ""
+	}
+	paths := make([]string, 0, len(patches))
+	for _, patch := range patches {
+		paths = append(paths, patch.Path)
+	}
+	return strings.Join(paths, ", ")
+}
+
+// recordAdmission appends an AdmissionEvent to s.events and, for a rejected
+// admission, emits a Kubernetes Event against the object that was denied -
+// called unconditionally from admit() via defer, so every return path is
+// covered exactly once. A dry-run request never actually mutated anything,
+// so it is skipped entirely here rather than recorded as if it had.
+// recordAdmission会向s.events追加一个AdmissionEvent，如果该次admission被
+// 拒绝，还会针对被拒绝的对象发出一个Kubernetes Event——通过defer在admit()
+// 中无条件调用，因此每条返回路径都恰好被覆盖一次。dry-run请求从未真正
+// mutate任何东西，所以这里会直接跳过它，而不是把它当作真的发生过来记录。
+func (s *AdmissionServer) recordAdmission(ctx context.Context, ar admissionv1.AdmissionReview, response *admissionv1.AdmissionResponse, status metrics_admission.AdmissionStatus, resource metrics_admission.AdmissionResource, latency time.Duration, dryRun bool) {
+	if ar.Request == nil || dryRun {
+		return
+	}
+	detail := eventDetailFrom(ctx)
+	event := AdmissionEvent{
+		Timestamp:      metav1.Now(),
+		Namespace:      ar.Request.Namespace,
+		Name:           ar.Request.Name,
+		Resource:       string(resource),
+		Allowed:        response.Allowed,
+		Patched:        len(response.Patch) > 0,
+		LatencySeconds: latency.Seconds(),
+	}
+	if detail != nil {
+		event.VPAName = detail.vpaName
+		event.Message = detail.summary
+	}
+	if !response.Allowed && response.Result != nil {
+		event.Message = response.Result.Message
+	}
+	s.events.Record(event)
+
+	if response.Allowed || s.eventRecorder == nil {
+		return
+	}
+	reason := "AdmissionRejected"
+	message := event.Message
+	if message == "" {
+		message = "admission request denied"
+	}
+	ref := &v1.ObjectReference{
+		Kind:       ar.Request.Kind.Kind,
+		APIVersion: ar.Request.Kind.Version,
+		Namespace:  ar.Request.Namespace,
+		Name:       ar.Request.Name,
+		UID:        ar.Request.UID,
+	}
+	s.eventRecorder.Eventf(ref, v1.EventTypeWarning, reason, message)
+}
+
+// requestAPIVersion peeks at just the apiVersion of the incoming
+// AdmissionReview, without fully decoding it, so Serve can echo the same
+// version back in its response. admission.k8s.io/v1beta1 clients (apiserver
+// versions before 1.22) reject a response claiming v1 even though the two
+// wire formats are otherwise field-for-field compatible. Falls back to v1 if
+// body isn't valid JSON or omits apiVersion - admit() will reject it shortly
+// after with a more specific error anyway.
+// requestAPIVersion只窥探传入AdmissionReview的apiVersion字段，而不完整解码它，
+// 这样Serve就能在响应中回传相同的版本。admission.k8s.io/v1beta1客户端
+// （1.22之前的apiserver版本）会拒绝声明为v1的响应，尽管两种线上格式在字段上
+// 是彼此兼容的。如果body不是合法的JSON或者没有apiVersion字段，则回退到v1——
+// admit()随后会给出更具体的报错。
+func requestAPIVersion(body []byte) string {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(body, &typeMeta); err != nil || typeMeta.APIVersion == "" {
+		return admissionv1.SchemeGroupVersion.String()
+	}
+	switch typeMeta.APIVersion {
+	case admissionv1beta1.SchemeGroupVersion.String():
+		return admissionv1beta1.SchemeGroupVersion.String()
+	default:
+		return admissionv1.SchemeGroupVersion.String()
+	}
 }
 
 // Serve is a handler function of AdmissionServer
@@ -346,6 +675,22 @@ func (s *AdmissionServer) admit(data []byte) (*v1beta1.AdmissionResponse, metric
 func (s *AdmissionServer) Serve(w http.ResponseWriter, r *http.Request) {
 	timer := metrics_admission.NewAdmissionLatency()
 	// 更新监控时间
+
+	select {
+	case <-s.ctx.Done():
+		// The server is draining (e.g. this replica just lost a leader
+		// election) - fail the request cleanly rather than processing it
+		// mid-shutdown; the apiserver will retry against another replica.
+		// server正在排空连接（例如该副本刚刚失去leader election）——与其在
+		// shutdown过程中继续处理这个请求，不如干净地让它失败；apiserver会
+		// 对另一个副本进行重试。
+		klog.V(2).InfoS("Rejecting admission request, server is shutting down")
+		http.Error(w, "admission controller is shutting down", http.StatusServiceUnavailable)
+		timer.Observe(metrics_admission.Error, metrics_admission.Unknown)
+		return
+	default:
+	}
+
 	var body []byte
 	if r.Body != nil {
 		if data, err := ioutil.ReadAll(r.Body); err == nil {
@@ -358,14 +703,34 @@ func (s *AdmissionServer) Serve(w http.ResponseWriter, r *http.Request) {
 	// 证明收到的请求是没问题的
 	contentType := r.Header.Get("Content-Type")
 	if contentType != "application/json" {
-		klog.Errorf("contentType=%s, expect application/json", contentType)
+		klog.ErrorS(nil, "Unexpected content type, expected application/json", "contentType", contentType)
 		timer.Observe(metrics_admission.Error, metrics_admission.Unknown)
 		return
 	}
 
-	reviewResponse, status, resource := s.admit(body)
+	// Propagate the incoming request's context (and thus its cancellation,
+	// e.g. if the kube-apiserver's admission timeout fires and the client
+	// connection is dropped) all the way down into admit().
+	// 把传入请求的context（以及随之而来的取消信号，例如kube-apiserver的admission
+	// 超时触发、客户端连接被断开）一路传递到admit()内部。
+	reviewResponse, status, resource := s.admit(r.Context(), body)
 	// admit函数从body中提取响应信息，辨别出是pod的响应还是vpa的响应，返回响应（里面存有patch信息），状态和资源类型
-	ar := v1beta1.AdmissionReview{
+	//
+	// admit() always decodes the request as admission.k8s.io/v1, which works
+	// for a v1beta1 request too - the two AdmissionReview/AdmissionRequest
+	// wire formats are field-for-field compatible, only the apiVersion
+	// string differs. What a v1beta1 client won't accept is a response whose
+	// TypeMeta claims v1, so Serve echoes back whatever apiVersion the
+	// request came in as.
+	// admit()总是把请求当作admission.k8s.io/v1来解码，这对v1beta1的请求同样
+	// 适用——两者的AdmissionReview/AdmissionRequest的线上格式逐字段兼容，只有
+	// apiVersion字符串不同。v1beta1客户端不能接受的是TypeMeta声明为v1的响应，
+	// 因此Serve会原样回传请求携带的apiVersion。
+	ar := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: requestAPIVersion(body),
+			Kind:       "AdmissionReview",
+		},
 		Response: reviewResponse,
 	}
 	// 进行回调
@@ -373,7 +738,7 @@ func (s *AdmissionServer) Serve(w http.ResponseWriter, r *http.Request) {
 	resp, err := json.Marshal(ar)
 	// json解析ar的信息
 	if err != nil {
-		klog.Error(err)
+		klog.ErrorS(err, "Cannot marshal admission review response", "resource", resource)
 		timer.Observe(metrics_admission.Error, resource)
 		// 进行监控，返回错误
 		return
@@ -381,7 +746,7 @@ func (s *AdmissionServer) Serve(w http.ResponseWriter, r *http.Request) {
 
 	if _, err := w.Write(resp); err != nil {
 		// 通过w将ar(resp)写入固定的路径中
-		klog.Error(err)
+		klog.ErrorS(err, "Cannot write admission review response", "resource", resource)
 		timer.Observe(metrics_admission.Error, resource)
 		// 进行监控 返回错误
 		return
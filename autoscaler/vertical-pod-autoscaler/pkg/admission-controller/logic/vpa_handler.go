@@ -0,0 +1,61 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	"context"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metrics_admission "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/admission"
+)
+
+func init() {
+	RegisterResourceHandler(func(s *AdmissionServer) ResourceHandler {
+		return &vpaResourceHandler{server: s}
+	})
+}
+
+// vpaResourceHandler is the built-in ResourceHandler for VerticalPodAutoscaler
+// objects, delegating to the existing vpaPreProcessor/validateVPA logic.
+type vpaResourceHandler struct {
+	server *AdmissionServer
+}
+
+func (h *vpaResourceHandler) GroupVersionResource() metav1.GroupVersionResource {
+	return metav1.GroupVersionResource{}
+}
+
+func (h *vpaResourceHandler) GroupResource() metav1.GroupResource {
+	return metav1.GroupResource{Group: "autoscaling.k8s.io", Resource: "verticalpodautoscalers"}
+}
+
+func (h *vpaResourceHandler) AdmissionResource() metrics_admission.AdmissionResource {
+	return metrics_admission.Vpa
+}
+
+func (h *vpaResourceHandler) GetPatches(ctx context.Context, raw []byte, namespace string, operation admissionv1.Operation) ([]patchRecord, error) {
+	return h.server.getPatchesForVPADefaults(raw, operation == admissionv1.Create)
+}
+
+// DisallowIncorrectObjects implements ResourceHandler - we don't let in
+// problematic VPA objects, since this late, in-process validation is the
+// only thing standing between a malformed ResourcePolicy/UpdatePolicy and
+// the rest of the VPA pipeline.
+func (h *vpaResourceHandler) DisallowIncorrectObjects() bool {
+	return true
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 
@@ -24,9 +25,15 @@ import (
 	"os"
 	"time"
 
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/common"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/admission-controller/logic"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/admission-controller/logic/recommenderpb"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/admission-controller/policy"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	policylisters "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1alpha1"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/limitrange"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics"
@@ -34,13 +41,23 @@ import (
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 	"k8s.io/client-go/informers"
 	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
 	kube_flag "k8s.io/component-base/cli/flag"
 	"k8s.io/klog"
 )
 
 const (
 	defaultResyncPeriod time.Duration = 10 * time.Minute
+
+	// recommendationProviderBuiltin and recommendationProviderGRPC are the
+	// valid values of --recommendation-provider.
+	recommendationProviderBuiltin = "builtin"
+	recommendationProviderGRPC    = "grpc"
 )
 
 var (
@@ -56,6 +73,41 @@ var (
 	webhookAddress = flag.String("webhook-address", "", "Address under which webhook is registered. Used when registerByURL is set to true.")
 	webhookPort    = flag.String("webhook-port", "", "Server Port for Webhook")
 	registerByURL  = flag.Bool("register-by-url", false, "If set to true, admission webhook will be registered by URL (webhookAddress:webhookPort) instead of by service name")
+	inPlaceResize  = flag.Bool("in-place-resize", false, "If set to true, VPAs in InPlace update mode will resize running pods via the resize subresource instead of always falling back to Recreate. Only takes effect if the API server advertises the resize subresource.")
+	inPlaceResizeChangeThreshold = flag.Float64("in-place-resize-container-change-threshold", 0.10,
+		"Containers whose relative change for every resource falls below this threshold are left out of an in-place resize patch entirely, so a pod with several containers only has the ones that actually changed resized.")
+
+	limitRangeCalculatorCacheTTL = flag.Duration("limit-range-calculator-cache-ttl", 30*time.Second,
+		`How long to cache a namespace's LimitRange bounds for. Sized to absorb the per-admission-request
+		re-list+merge on high-churn clusters; set to 0 to disable caching and always hit the inner calculator.`)
+
+	recommendationProviderKind = flag.String("recommendation-provider", recommendationProviderBuiltin,
+		`Which RecommendationProvider implementation to use: "builtin" (read the recommendation cached on the matching VerticalPodAutoscaler's status, the long-standing behavior) or "grpc" (also call an external recommendation provider, falling back to the cached VPA recommendation on error or timeout).`)
+	recommendationProviderEndpoint = flag.String("recommendation-provider-endpoint", "",
+		`Address of the external gRPC recommendation provider to dial. Required, and only consulted, when --recommendation-provider=grpc.`)
+	recommendationProviderTimeout = flag.Duration("recommendation-provider-timeout", 50*time.Millisecond,
+		`Deadline for a single external recommendation provider call before falling back to the cached VPA recommendation. Only consulted when --recommendation-provider=grpc.`)
+	// 以上三个参数用于配置可插拔的外部gRPC recommendation provider——如果调用
+	// 超时或失败，控制器将采用缓存在VPA对象中的资源建议。
+
+	leaderElect = flag.Bool("leader-elect", false,
+		`Start a leader election client and only run selfRegistration on the elected leader.
+		Enable this when running the admission controller with more than one replica.`)
+	leaderElectLeaseDuration = flag.Duration("leader-elect-lease-duration", 15*time.Second,
+		`The duration that non-leader candidates will wait after observing a leadership
+		renewal until attempting to acquire leadership of the lease.`)
+	leaderElectRenewDeadline = flag.Duration("leader-elect-renew-deadline", 10*time.Second,
+		`The interval between attempts by the acting leader to renew its leadership before it stops leading.`)
+	leaderElectRetryPeriod = flag.Duration("leader-elect-retry-period", 2*time.Second,
+		`The duration the clients should wait between attempting acquisition and renewal of a leadership.`)
+	leaderElectResourceLock = flag.String("leader-elect-resource-lock", resourcelock.LeasesResourceLock,
+		`The type of resource object that is used for locking during leader election.`)
+	leaderElectResourceName = flag.String("leader-elect-resource-name", "vpa-admission-controller",
+		`The name of resource object that is used for locking during leader election.`)
+	leaderElectResourceNamespace = flag.String("leader-elect-resource-namespace", "kube-system",
+		`The namespace of resource object that is used for locking during leader election.`)
+	// 以上七个参数用于配置admission controller的leader election，使得只有被选举出的
+	// leader才会(重新)注册MutatingWebhookConfiguration，避免多副本部署时重复注册
 )
 /**
 certsConfiguration 部分是证书的config配置区域，将指定的CA证书或者server证书地址进行config。
@@ -127,16 +179,70 @@ func main() {
 	if err != nil {
 		klog.Errorf("Failed to create limitRangeCalculator, falling back to not checking limits. Error message: %s", err)
 		limitRangeCalculator = limitrange.NewNoopLimitsCalculator()
+	} else if *limitRangeCalculatorCacheTTL > 0 {
+		// 包装一层TTL缓存，避免每个admission请求都重新list+merge该命名空间的
+		// LimitRange/ResourceQuota。
+		// Wrap it with a TTL cache so every admission request doesn't re-list
+		// and re-merge that namespace's LimitRanges/ResourceQuotas.
+		limitRangeCalculator = limitrange.NewCachedLimitsRangeCalculator(limitRangeCalculator, *limitRangeCalculatorCacheTTL)
 	}
 
 	//第十步：连接Recommendation（关键步骤）
 	// 控制器AC会拦截Pod的创建请求，如果Pod与未设置为off模式的VPA配置匹配，控制器通过将推荐资源应用到Pod spec来重写请求。
 	// AC通过从Recommender获取推荐资源，如果调用超时或失败，控制器将采用缓存在VPA对象中的资源建议。如果这也是不可用的，控制器采取最初指定的资源。
-	recommendationProvider := logic.NewRecommendationProvider(limitRangeCalculator, vpa_api_util.NewCappingRecommendationProcessor(limitRangeCalculator), targetSelectorFetcher, vpaLister)
+	var recommendationProvider logic.RecommendationProvider = logic.NewRecommendationProvider(limitRangeCalculator, vpa_api_util.NewCappingRecommendationProcessor(limitRangeCalculator), targetSelectorFetcher, vpaLister, kubeClient)
+	if *recommendationProviderKind == recommendationProviderGRPC {
+		// 用一个额外的gRPC recommendation provider包装builtin的
+		// recommendationProvider：gRPC调用成功时用它改进过的建议覆盖结果，
+		// 超时或失败时原样回退到builtin已经算好的、缓存在VPA对象中的建议。
+		conn, err := grpc.Dial(*recommendationProviderEndpoint, grpc.WithInsecure())
+		if err != nil {
+			klog.Fatalf("Failed to dial external recommendation provider at %s: %v", *recommendationProviderEndpoint, err)
+		}
+		recommendationProvider = logic.NewGRPCRecommendationProvider(recommenderpb.NewRecommenderClient(conn), *recommendationProviderTimeout, recommendationProvider)
+	} else if *recommendationProviderKind != recommendationProviderBuiltin {
+		klog.Fatalf("Unknown --recommendation-provider %q, must be %q or %q", *recommendationProviderKind, recommendationProviderBuiltin, recommendationProviderGRPC)
+	}
+
+	// 通过一个watch VerticalPodAutoscalerAdmissionPolicy CRD的informer，构建出
+	// 供Admission Server查询的policyProvider，以及保持其缓存同步、校验spec的
+	// controller。策略在运行时生效，无需重启admission controller。
+	// Build the policyProvider the Admission Server consults, backed by an
+	// informer watching the VerticalPodAutoscalerAdmissionPolicy CRD, and the
+	// controller that keeps its cache in sync and validates each spec.
+	// Policies take effect at runtime, with no admission controller restart.
+	policyInformer := policy.NewInformer(vpaClient, defaultResyncPeriod)
+	policyLister := policylisters.NewVerticalPodAutoscalerAdmissionPolicyLister(policyInformer.GetIndexer())
+	policyProvider := policy.NewCache(policyLister)
+	policyController := policy.NewController(vpaClient, policyInformer)
+	stopCh := make(chan struct{})
+	go policyInformer.Run(stopCh)
+	go policyController.Run(2, stopCh)
 
 	//十一步：Admission Server（关键）
+	// 检查API Server是否支持pods/resize子资源，只有InPlace模式依赖这一支持
+	inPlaceResizeSupported := *inPlaceResize && podResizeSubresourceSupported(kubeClient)
+	// serverCtx is cancelled once this replica stops being the leader (when
+	// leader election is enabled), so the AdmissionServer can reject
+	// in-flight work while the HTTP server drains instead of being killed
+	// mid-request.
+	// serverCtx会在该副本不再是leader时被取消（当leader election启用时），
+	// 这样AdmissionServer就能在HTTP server排空连接的过程中拒绝正在处理的请求，
+	// 而不是在请求处理中途被直接杀掉。
+	serverCtx, cancelServer := context.WithCancel(context.Background())
+	// admissionEventBroadcaster用于把被拒绝的admission请求作为Event发送到
+	// 被拒绝对象上（kubectl describe可见），与runAsLeaderElected里leader
+	// election自己用的broadcaster相互独立，因为这里要广播的是admission结果，
+	// 和leader选举没有关系。
+	// admissionEventBroadcaster publishes rejected admission requests as
+	// Events on the rejected object (visible via kubectl describe) -
+	// deliberately separate from the broadcaster runAsLeaderElected builds for
+	// itself, since these events are about admission outcomes, not leadership.
+	admissionEventBroadcaster := record.NewBroadcaster()
+	admissionEventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	admissionEventRecorder := admissionEventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "vpa-admission-controller"})
 	// 创建Admission Server服务as
-	as := logic.NewAdmissionServer(recommendationProvider, podPreprocessor, vpaPreprocessor, limitRangeCalculator)
+	as := logic.NewAdmissionServer(recommendationProvider, podPreprocessor, vpaPreprocessor, limitRangeCalculator, inPlaceResizeSupported, *inPlaceResizeChangeThreshold, serverCtx, policyProvider, admissionEventRecorder)
 
 	// handle函数来处理服务传递（http）
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -145,6 +251,11 @@ func main() {
 		healthCheck.UpdateLastActivity()
 		// 更新healthCheck，持续监控
 	})
+	// /admissions/watch暴露一个调试用的admission决策事件流，方便在不接入
+	// Prometheus的情况下也能实时看到admission controller最近做出的决定。
+	// /admissions/watch exposes a debug stream of admission decisions, so
+	// recent admission outcomes can be inspected live without Prometheus.
+	http.HandleFunc("/admissions/watch", as.WatchAdmissions)
 
 	clientset := getClient()
 	// 获取k8s集群客户端
@@ -153,9 +264,113 @@ func main() {
 		TLSConfig: configTLS(clientset, certs.serverCert, certs.serverKey),
 	}
 
+	// The HTTPS server always runs, on every replica, leader or not - /
+	// must stay reachable on every replica's Service endpoint so non-leader
+	// replicas don't get marked unready.
+	// HTTPS server在每一个副本上都会一直运行，无论是不是leader——/必须在每个
+	// 副本的Service endpoint上保持可达，这样非leader副本才不会被标记为未就绪。
+	go server.ListenAndServeTLS("", "")
+
 	url := fmt.Sprintf("%v:%v", *webhookAddress, *webhookPort)
-	//协程注册ca认证
-	go selfRegistration(clientset, certs.caCert, &namespace, url, *registerByURL)
-	//持续监听服务
-	server.ListenAndServeTLS("", "")
+	registerWebhook := func() {
+		selfRegistration(clientset, certs.caCert, &namespace, url, *registerByURL)
+	}
+
+	if !*leaderElect {
+		go registerWebhook()
+		select {}
+	}
+
+	// 只有被选举出的leader才会(重新)注册MutatingWebhookConfiguration；失去
+	// leadership时，优雅地排空正在处理的admission请求后退出进程，让
+	// kubernetes重启并重新进入下一轮选举。
+	runAsLeaderElected(kubeClient, server, cancelServer, registerWebhook)
+}
+
+// runAsLeaderElected blocks running the leader election client. The elected
+// leader calls register once and keeps leading until it loses the lease, at
+// which point it cancels serverCtx (via cancelServer), gives the server a
+// grace period to drain in-flight requests, and exits so Kubernetes restarts
+// it into the next round of standby/election - mirroring the
+// OnStartedLeading/OnStoppedLeading pattern kube-scheduler uses for its own
+// leader election.
+// runAsLeaderElected阻塞地运行leader election客户端。被选举出的leader会调用
+// 一次register，并持续担任leader直到失去lease；届时它会（通过cancelServer）
+// 取消serverCtx，给server一段宽限期去排空正在处理的请求，然后退出进程，让
+// kubernetes把它重启进入下一轮standby/选举——这和kube-scheduler自己的leader
+// election所采用的OnStartedLeading/OnStoppedLeading模式是一致的。
+func runAsLeaderElected(kubeClient kube_client.Interface, server *http.Server, cancelServer context.CancelFunc, register func()) {
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Unable to get hostname: %v", err)
+	}
+	id = id + "_" + string(uuid.NewUUID())
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(*leaderElectResourceNamespace)})
+	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "vpa-admission-controller"})
+
+	lock, err := resourcelock.New(
+		*leaderElectResourceLock,
+		*leaderElectResourceNamespace,
+		*leaderElectResourceName,
+		kubeClient.CoreV1(),
+		kubeClient.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: eventRecorder,
+		},
+	)
+	if err != nil {
+		klog.Fatalf("Unable to create leader election lock: %v", err)
+	}
+
+	leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: *leaderElectLeaseDuration,
+		RenewDeadline: *leaderElectRenewDeadline,
+		RetryPeriod:   *leaderElectRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				register()
+				<-ctx.Done()
+			},
+			OnStoppedLeading: func() {
+				klog.Infof("%s stopped being the leader, shutting down", id)
+				cancelServer()
+				shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer shutdownCancel()
+				server.Shutdown(shutdownCtx)
+				os.Exit(0)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					klog.Infof("new leader elected: %s", identity)
+				}
+			},
+		},
+	})
+}
+
+// podResizeSubresourceSupported reports whether the API server kubeClient
+// talks to advertises the pods/resize subresource (available from
+// Kubernetes 1.27+ with in-place pod vertical scaling). Falls back to false
+// on any discovery error, since that's the safe choice - it just means
+// InPlace VPAs behave like Recreate until the cluster is upgraded.
+// podResizeSubresourceSupported表示kubeClient所连接的API server是否支持
+// pods/resize子资源（Kubernetes 1.27+原地pod垂直扩缩所引入）。发现过程出错时
+// 回退为false，这是安全的选择——只是意味着在集群升级之前，InPlace的VPA会表现
+// 得和Recreate一样。
+func podResizeSubresourceSupported(kubeClient kube_client.Interface) bool {
+	resources, err := kubeClient.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		klog.Warningf("Failed to discover v1 API resources, assuming no in-place resize support: %v", err)
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "pods/resize" {
+			return true
+		}
+	}
+	return false
 }
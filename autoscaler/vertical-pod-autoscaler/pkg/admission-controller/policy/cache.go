@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package policy implements AdmissionServer's AdmissionPolicyProvider:
+// a lister-backed cache of VerticalPodAutoscalerAdmissionPolicy objects kept
+// current by a controller that watches the CRD, plus validation of each
+// object before it's trusted.
+// policy包实现了AdmissionServer的AdmissionPolicyProvider：一个由lister支撑的
+// VerticalPodAutoscalerAdmissionPolicy对象缓存，由一个watch该CRD的controller
+// 保持同步；此外还包含了在信任每个对象之前对其进行的校验。
+package policy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	policy_v1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1alpha1"
+	policylisters "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1alpha1"
+	"k8s.io/klog/v2"
+)
+
+// NamespacePolicyPrefix is the fixed name every VerticalPodAutoscalerAdmissionPolicy
+// must use. There is exactly one policy per namespace - like a ResourceQuota,
+// its name doesn't matter for lookup, only its namespace does - but requiring
+// a single well-known name avoids the ambiguity of picking one among several
+// objects in the same namespace.
+const NamespacePolicyName = "default"
+
+// Cache is an AdmissionPolicyProvider backed by a policylisters.VerticalPodAutoscalerAdmissionPolicyLister.
+// It never talks to the API server directly - Controller is responsible for
+// keeping the lister's store in sync and for only letting valid objects
+// reach it.
+// Cache是一个由policylisters.VerticalPodAutoscalerAdmissionPolicyLister支撑的
+// AdmissionPolicyProvider。它从不直接与API server通信——由Controller负责让
+// lister的store保持同步，并且只让合法的对象进入其中。
+type Cache struct {
+	lister policylisters.VerticalPodAutoscalerAdmissionPolicyLister
+}
+
+// NewCache returns a Cache reading from lister.
+func NewCache(lister policylisters.VerticalPodAutoscalerAdmissionPolicyLister) *Cache {
+	return &Cache{lister: lister}
+}
+
+// PolicyFor implements logic.AdmissionPolicyProvider.
+func (c *Cache) PolicyFor(namespace string) *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicySpec {
+	obj, err := c.lister.VerticalPodAutoscalerAdmissionPolicies(namespace).Get(NamespacePolicyName)
+	if err != nil {
+		// Not found is the overwhelmingly common case (most namespaces never
+		// opt into a policy) so it's not worth logging; any other lister
+		// error (e.g. store not yet synced) is unexpected and worth a trace.
+		// Not found是绝大多数情况下的结果（大部分namespace永远不会启用
+		// policy），所以不值得记录日志；任何其他lister错误（例如store还未
+		// 同步完成）都是意料之外的，值得记一条trace。
+		klog.V(6).InfoS("no admission policy for namespace", "namespace", namespace, "err", err)
+		return nil
+	}
+	if !isValid(obj) {
+		klog.V(4).InfoS("ignoring invalid admission policy", "namespace", namespace, "name", obj.Name)
+		return nil
+	}
+	spec := obj.Spec
+	return &spec
+}
+
+// isValid reports whether obj's latest reconciled Status carries a True
+// AdmissionPolicyValid condition. Controller is the only writer of this
+// condition; Cache only reads it.
+func isValid(obj *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicy) bool {
+	for _, condition := range obj.Status.Conditions {
+		if condition.Type == policy_v1alpha1.AdmissionPolicyValid {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	// No condition recorded yet (e.g. the controller hasn't reconciled this
+	// generation). Trust an unreconciled policy rather than silently
+	// ignoring it, since Status lags Spec by design.
+	return true
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	policy_v1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1alpha1"
+	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// maxRetries is how many times a namespace key is retried, with
+	// exponential backoff, before Controller gives up on it and just waits
+	// for the next watch event to re-enqueue it.
+	maxRetries = 5
+)
+
+// Controller watches VerticalPodAutoscalerAdmissionPolicy objects, validates
+// each one, and writes the outcome back to its Status - it never touches
+// Spec. The informer it watches also backs Cache, so by the time a reconcile
+// runs, the object it validates is already visible to PolicyFor.
+// Controller watch VerticalPodAutoscalerAdmissionPolicy对象，对每一个对象
+// 进行校验，并把结果写回其Status——它从不改动Spec。它所watch的informer同时也
+// 支撑着Cache，所以当一次reconcile运行时，它所校验的对象已经对PolicyFor可见。
+type Controller struct {
+	client   vpa_clientset.Interface
+	informer cache.SharedIndexInformer
+	queue    workqueue.RateLimitingInterface
+}
+
+// NewController returns a Controller reading from informer (typically the
+// same informer Cache's lister is built from) and writing Status updates
+// through client.
+func NewController(client vpa_clientset.Interface, informer cache.SharedIndexInformer) *Controller {
+	c := &Controller{
+		client:   client,
+		informer: informer,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+	}
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    c.enqueue,
+		UpdateFunc: func(_, newObj interface{}) { c.enqueue(newObj) },
+		DeleteFunc: c.enqueue,
+	})
+	return c
+}
+
+func (c *Controller) enqueue(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("couldn't get key for object %+v: %v", obj, err))
+		return
+	}
+	c.queue.Add(key)
+}
+
+// Run starts workers reconciling until stopCh is closed, blocking until then.
+func (c *Controller) Run(workers int, stopCh <-chan struct{}) {
+	defer runtime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.InfoS("Starting admission policy controller")
+	if !cache.WaitForCacheSync(stopCh, c.informer.HasSynced) {
+		runtime.HandleError(fmt.Errorf("timed out waiting for admission policy informer to sync"))
+		return
+	}
+
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, stopCh)
+	}
+	<-stopCh
+	klog.InfoS("Stopping admission policy controller")
+}
+
+func (c *Controller) runWorker() {
+	for c.processNextItem() {
+	}
+}
+
+func (c *Controller) processNextItem() bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	err := c.reconcile(key.(string))
+	c.handleErr(err, key)
+	return true
+}
+
+func (c *Controller) handleErr(err error, key interface{}) {
+	if err == nil {
+		c.queue.Forget(key)
+		return
+	}
+	if c.queue.NumRequeues(key) < maxRetries {
+		klog.V(2).InfoS("Error reconciling admission policy, retrying", "key", key, "err", err)
+		c.queue.AddRateLimited(key)
+		return
+	}
+	klog.ErrorS(err, "Dropping admission policy out of queue after repeated errors", "key", key)
+	c.queue.Forget(key)
+	runtime.HandleError(err)
+}
+
+// reconcile validates the policy named by key (namespace/name) and writes
+// the result to its Status.Conditions. A deleted policy needs no action -
+// Cache simply stops being able to Get it.
+func (c *Controller) reconcile(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+
+	obj, exists, err := c.informer.GetStore().GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		klog.V(4).InfoS("admission policy deleted, nothing to reconcile", "namespace", namespace, "name", name)
+		return nil
+	}
+	policyObj, ok := obj.(*policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicy)
+	if !ok {
+		return fmt.Errorf("unexpected object type %T for admission policy %s", obj, key)
+	}
+
+	condition := validate(policyObj)
+	return c.updateStatus(policyObj, condition)
+}
+
+// validate bounds-checks policyObj.Spec, returning the VerticalPodAutoscalerAdmissionPolicyCondition
+// to record. The only currently-enforced rule is that every resource with
+// both a min and a max overlay has min <= max - the same rule the admission
+// server itself applies to a VPA's own ResourcePolicy.
+func validate(policyObj *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicy) policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition {
+	condition := policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition{
+		Type:               policy_v1alpha1.AdmissionPolicyValid,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+	}
+	spec := policyObj.Spec
+	for name, min := range spec.MinAllowedOverlay {
+		if max, found := spec.MaxAllowedOverlay[name]; found && max.Cmp(min) < 0 {
+			condition.Status = corev1.ConditionFalse
+			condition.Reason = "MinAllowedOverlayAboveMax"
+			condition.Message = fmt.Sprintf("minAllowedOverlay for %s is greater than maxAllowedOverlay", name)
+			return condition
+		}
+	}
+	return condition
+}
+
+func (c *Controller) updateStatus(policyObj *policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicy, condition policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition) error {
+	updated := policyObj.DeepCopy()
+	updated.Status.ObservedGeneration = updated.Generation
+	updated.Status.Conditions = replaceCondition(updated.Status.Conditions, condition)
+
+	_, err := c.client.AutoscalingV1alpha1().VerticalPodAutoscalerAdmissionPolicies(updated.Namespace).UpdateStatus(context.TODO(), updated, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		// Another writer updated the object between our Get and our Update -
+		// the queue will redeliver this key on the next resync or watch
+		// event, so there's nothing more to do here.
+		// 在我们的Get和Update之间，另一个写入者更新了该对象——queue会在下一次
+		// resync或watch事件时重新投递这个key，所以这里不需要再做什么。
+		return nil
+	}
+	return err
+}
+
+func replaceCondition(conditions []policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition, condition policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition) []policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicyCondition {
+	for i, existing := range conditions {
+		if existing.Type == condition.Type {
+			if existing.Status == condition.Status {
+				// No transition - keep the original LastTransitionTime.
+				condition.LastTransitionTime = existing.LastTransitionTime
+			}
+			conditions[i] = condition
+			return conditions
+		}
+	}
+	return append(conditions, condition)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	policy_v1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1alpha1"
+	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewInformer returns an unstarted SharedIndexInformer over every
+// VerticalPodAutoscalerAdmissionPolicy in the cluster, resynced every
+// resyncPeriod. Both Cache and Controller are built against the same
+// informer - call informer.Run(stopCh) once (not once per consumer) after
+// constructing both.
+func NewInformer(client vpa_clientset.Interface, resyncPeriod time.Duration) cache.SharedIndexInformer {
+	listWatch := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return client.AutoscalingV1alpha1().VerticalPodAutoscalerAdmissionPolicies(metav1.NamespaceAll).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return client.AutoscalingV1alpha1().VerticalPodAutoscalerAdmissionPolicies(metav1.NamespaceAll).Watch(context.TODO(), options)
+		},
+	}
+	return cache.NewSharedIndexInformer(
+		listWatch,
+		&policy_v1alpha1.VerticalPodAutoscalerAdmissionPolicy{},
+		resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
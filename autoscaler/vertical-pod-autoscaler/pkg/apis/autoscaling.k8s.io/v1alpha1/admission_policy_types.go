@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds the autoscaling.k8s.io/v1alpha1 extensions to the
+// VerticalPodAutoscaler API family. Unlike the poc.autoscaling.k8s.io/v1alpha1
+// package, these types are namespaced configuration objects consumed by the
+// admission controller itself, rather than extensions of the VPA object.
+// v1alpha1包保存了VerticalPodAutoscaler API家族的autoscaling.k8s.io/v1alpha1
+// 扩展。和poc.autoscaling.k8s.io/v1alpha1包不同，这里的类型是被admission
+// controller自身消费的、按namespace配置的对象，而不是对VPA对象本身的扩展。
+package v1alpha1
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VerticalPodAutoscalerAdmissionPolicy lets operators tune how the admission
+// controller treats pods in its own namespace, without restarting it: put it
+// in dry-run mode, exclude noisy containers, clamp recommendations with a
+// namespace-wide min/max overlay, or supply resourcePolicy/annotation
+// defaults for VPAs that don't set their own. The admission controller
+// watches these objects and re-reads the current one for a pod's namespace
+// on every admission request - see AdmissionPolicyProvider.
+// VerticalPodAutoscalerAdmissionPolicy允许运营商在不重启admission controller
+// 的情况下，调整它在自己所在namespace里对pod的处理方式：进入dry-run模式、
+// 排除吵闹的容器、用一个namespace级别的min/max覆盖层来钳制recommendation，
+// 或者为没有自己设置resourcePolicy/annotation的VPA提供默认值。admission
+// controller会watch这些对象，并在每次admission请求时为该pod所在namespace
+// 重新读取当前对象——见AdmissionPolicyProvider。
+type VerticalPodAutoscalerAdmissionPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VerticalPodAutoscalerAdmissionPolicySpec   `json:"spec"`
+	Status VerticalPodAutoscalerAdmissionPolicyStatus `json:"status,omitempty"`
+}
+
+// VerticalPodAutoscalerAdmissionPolicySpec is the desired admission-time
+// behavior for every pod in this policy's namespace.
+type VerticalPodAutoscalerAdmissionPolicySpec struct {
+	// DryRun, if true, makes the admission controller compute patches as
+	// usual but never include them in the AdmissionResponse - useful for
+	// observing what a policy or VPA change would do before it takes effect.
+	// A "would-patch" metric is still recorded so the dry run is observable.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// ExcludedContainers lists container names that are never patched,
+	// regardless of what their VPA recommends - e.g. sidecars injected by
+	// another controller that would fight over ownership of their resources.
+	ExcludedContainers []string `json:"excludedContainers,omitempty"`
+
+	// MinAllowedOverlay and MaxAllowedOverlay clamp every recommendation
+	// applied to a pod in this namespace, in addition to (not instead of)
+	// whatever the owning VPA's own ResourcePolicy specifies - the tighter
+	// of the two bounds wins.
+	MinAllowedOverlay v1.ResourceList `json:"minAllowedOverlay,omitempty"`
+	MaxAllowedOverlay v1.ResourceList `json:"maxAllowedOverlay,omitempty"`
+
+	// ResourcePolicyDefault is used to fill in a container's resource policy
+	// when its VPA doesn't specify one for that container, instead of the
+	// admission controller's built-in defaults.
+	ResourcePolicyDefault *vpa_types.PodResourcePolicy `json:"resourcePolicyDefault,omitempty"`
+
+	// AnnotationTemplate overrides the default "Pod resources updated by
+	// %s: %s" annotation value applied to patched pods. %s placeholders are
+	// filled in the same order as the built-in template: VPA name, then the
+	// per-container update summary.
+	AnnotationTemplate string `json:"annotationTemplate,omitempty"`
+}
+
+// VerticalPodAutoscalerAdmissionPolicyStatus surfaces whether the policy
+// controller has accepted this object's spec.
+type VerticalPodAutoscalerAdmissionPolicyStatus struct {
+	// ObservedGeneration is the .metadata.generation the policy controller
+	// last reconciled, so callers can tell whether Conditions are current.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	Conditions []VerticalPodAutoscalerAdmissionPolicyCondition `json:"conditions,omitempty"`
+}
+
+// VerticalPodAutoscalerAdmissionPolicyConditionType is a valid value for
+// VerticalPodAutoscalerAdmissionPolicyCondition.Type.
+type VerticalPodAutoscalerAdmissionPolicyConditionType string
+
+const (
+	// AdmissionPolicyValid is True when the policy controller has validated
+	// this object's spec (e.g. MinAllowedOverlay <= MaxAllowedOverlay for
+	// every resource) and False with Reason/Message set otherwise. An
+	// invalid policy is never consulted by the admission server - the
+	// previously-valid version (if any) stays cached instead.
+	AdmissionPolicyValid VerticalPodAutoscalerAdmissionPolicyConditionType = "Valid"
+)
+
+// VerticalPodAutoscalerAdmissionPolicyCondition describes the state of a
+// VerticalPodAutoscalerAdmissionPolicy at a point in time.
+type VerticalPodAutoscalerAdmissionPolicyCondition struct {
+	Type               VerticalPodAutoscalerAdmissionPolicyConditionType `json:"type"`
+	Status             v1.ConditionStatus                                `json:"status"`
+	LastTransitionTime metav1.Time                                       `json:"lastTransitionTime,omitempty"`
+	Reason             string                                            `json:"reason,omitempty"`
+	Message            string                                            `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VerticalPodAutoscalerAdmissionPolicyList is a list of
+// VerticalPodAutoscalerAdmissionPolicy objects.
+type VerticalPodAutoscalerAdmissionPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []VerticalPodAutoscalerAdmissionPolicy `json:"items"`
+}
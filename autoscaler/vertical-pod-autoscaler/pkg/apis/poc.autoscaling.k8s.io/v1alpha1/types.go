@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds the poc.autoscaling.k8s.io/v1alpha1 proof-of-concept
+// extensions to the VerticalPodAutoscaler API that have not yet graduated
+// into autoscaling.k8s.io. Fields here may still change in incompatible ways.
+// v1alpha1包保存了尚未晋升到autoscaling.k8s.io的poc.autoscaling.k8s.io/v1alpha1
+// 概念验证性扩展。这里的字段仍然可能发生不兼容的变化。
+package v1alpha1
+
+import (
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+// ContainerResourcePolicy extends the stable v1 ContainerResourcePolicy with
+// multi-container targeting modeled on autoscaling/v2's
+// ContainerResourceMetricSource: instead of every container being sized off
+// its own histogram, a single container's usage can drive the recommendation
+// for the whole pod (or for specific sidecars).
+// ContainerResourcePolicy对稳定版v1的ContainerResourcePolicy做了扩展，
+// 借鉴了autoscaling/v2中ContainerResourceMetricSource的多容器定向方式：
+// 不再是每个容器都按自己的直方图独立定size，而是可以由某一个容器的用量
+// 驱动整个pod（或特定sidecar）的recommendation。
+type ContainerResourcePolicy struct {
+	vpa_types.ContainerResourcePolicy `json:",inline"`
+
+	// ScalingSignal names the container whose usage histogram should drive
+	// the recommendation for this container, instead of this container's own
+	// histogram. Leave empty to keep the existing per-container behavior.
+	// ScalingSignal指定应由哪个容器的用量直方图来驱动该容器的recommendation，
+	// 而不是使用该容器自身的直方图。留空则保持现有的逐容器独立行为。
+	ScalingSignal string `json:"scalingSignal,omitempty"`
+
+	// SidecarMode controls how a container tracking ScalingSignal derives its
+	// target from the signal container's recommendation.
+	// SidecarMode控制一个跟踪ScalingSignal的容器，如何从信号容器的
+	// recommendation中推导出自己的target。
+	SidecarMode *SidecarMode `json:"sidecarMode,omitempty"`
+}
+
+// SidecarMode describes how a dependent container's target is derived from
+// the ScalingSignal container's recommendation.
+type SidecarMode string
+
+const (
+	// SidecarModeProportional scales the dependent container's target by the
+	// same ratio the signal container's target moved, relative to its
+	// initial/request value.
+	SidecarModeProportional SidecarMode = "Proportional"
+	// SidecarModeFixed keeps the dependent container pinned to its own
+	// MinAllowed/MaxAllowed (or current request if unset), ignoring the
+	// signal container's recommendation entirely.
+	SidecarModeFixed SidecarMode = "Fixed"
+	// SidecarModeMirror sets the dependent container's target equal to the
+	// signal container's target, clamped to the dependent container's own
+	// MinAllowed/MaxAllowed.
+	SidecarModeMirror SidecarMode = "Mirror"
+)
+
+// PodResourcePolicy is the v1alpha1 mirror of v1.PodResourcePolicy, using the
+// extended ContainerResourcePolicy above in place of the stable one.
+type PodResourcePolicy struct {
+	ContainerPolicies []ContainerResourcePolicy `json:"containerPolicies,omitempty"`
+}
+
+// UpgradeContainerResourcePolicy migrates a v1 ContainerResourcePolicy to the
+// v1alpha1 shape, applying sensible defaults (no ScalingSignal, i.e.
+// unchanged per-container behavior) so existing VPA objects keep working
+// unmodified until an operator opts into the new fields.
+// UpgradeContainerResourcePolicy将一个v1的ContainerResourcePolicy迁移到v1alpha1
+// 的形态，并应用合理的默认值（不设置ScalingSignal，即维持原有的逐容器独立行为），
+// 这样现有的VPA对象在运营商主动启用新字段之前都能保持不变地正常工作。
+func UpgradeContainerResourcePolicy(policy vpa_types.ContainerResourcePolicy) ContainerResourcePolicy {
+	return ContainerResourcePolicy{
+		ContainerResourcePolicy: policy,
+	}
+}
+
+// UpgradePodResourcePolicy migrates an entire v1 PodResourcePolicy to the
+// v1alpha1 shape, container by container.
+func UpgradePodResourcePolicy(policy *vpa_types.PodResourcePolicy) *PodResourcePolicy {
+	if policy == nil {
+		return nil
+	}
+	upgraded := &PodResourcePolicy{
+		ContainerPolicies: make([]ContainerResourcePolicy, 0, len(policy.ContainerPolicies)),
+	}
+	for _, containerPolicy := range policy.ContainerPolicies {
+		upgraded.ContainerPolicies = append(upgraded.ContainerPolicies, UpgradeContainerResourcePolicy(containerPolicy))
+	}
+	return upgraded
+}
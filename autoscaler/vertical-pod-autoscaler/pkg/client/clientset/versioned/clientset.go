@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	autoscalingv1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1"
+	autoscalingv1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1alpha1"
 	autoscalingv1beta1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta1"
 	autoscalingv1beta2 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1beta2"
 	pocv1alpha1 "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/poc.autoscaling.k8s.io/v1alpha1"
@@ -35,6 +36,7 @@ type Interface interface {
 	AutoscalingV1() autoscalingv1.AutoscalingV1Interface
 	AutoscalingV1beta2() autoscalingv1beta2.AutoscalingV1beta2Interface
 	AutoscalingV1beta1() autoscalingv1beta1.AutoscalingV1beta1Interface
+	AutoscalingV1alpha1() autoscalingv1alpha1.AutoscalingV1alpha1Interface
 	PocV1alpha1() pocv1alpha1.PocV1alpha1Interface
 }
 
@@ -42,10 +44,11 @@ type Interface interface {
 // version included in a Clientset.
 type Clientset struct {
 	*discovery.DiscoveryClient
-	autoscalingV1      *autoscalingv1.AutoscalingV1Client
-	autoscalingV1beta2 *autoscalingv1beta2.AutoscalingV1beta2Client
-	autoscalingV1beta1 *autoscalingv1beta1.AutoscalingV1beta1Client
-	pocV1alpha1        *pocv1alpha1.PocV1alpha1Client
+	autoscalingV1       *autoscalingv1.AutoscalingV1Client
+	autoscalingV1beta2  *autoscalingv1beta2.AutoscalingV1beta2Client
+	autoscalingV1beta1  *autoscalingv1beta1.AutoscalingV1beta1Client
+	autoscalingV1alpha1 *autoscalingv1alpha1.AutoscalingV1alpha1Client
+	pocV1alpha1         *pocv1alpha1.PocV1alpha1Client
 }
 
 // AutoscalingV1 retrieves the AutoscalingV1Client
@@ -63,6 +66,11 @@ func (c *Clientset) AutoscalingV1beta1() autoscalingv1beta1.AutoscalingV1beta1In
 	return c.autoscalingV1beta1
 }
 
+// AutoscalingV1alpha1 retrieves the AutoscalingV1alpha1Client
+func (c *Clientset) AutoscalingV1alpha1() autoscalingv1alpha1.AutoscalingV1alpha1Interface {
+	return c.autoscalingV1alpha1
+}
+
 // PocV1alpha1 retrieves the PocV1alpha1Client
 func (c *Clientset) PocV1alpha1() pocv1alpha1.PocV1alpha1Interface {
 	return c.pocV1alpha1
@@ -101,6 +109,10 @@ func NewForConfig(c *rest.Config) (*Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	cs.autoscalingV1alpha1, err = autoscalingv1alpha1.NewForConfig(&configShallowCopy)
+	if err != nil {
+		return nil, err
+	}
 	cs.pocV1alpha1, err = pocv1alpha1.NewForConfig(&configShallowCopy)
 	if err != nil {
 		return nil, err
@@ -120,6 +132,7 @@ func NewForConfigOrDie(c *rest.Config) *Clientset {
 	cs.autoscalingV1 = autoscalingv1.NewForConfigOrDie(c)
 	cs.autoscalingV1beta2 = autoscalingv1beta2.NewForConfigOrDie(c)
 	cs.autoscalingV1beta1 = autoscalingv1beta1.NewForConfigOrDie(c)
+	cs.autoscalingV1alpha1 = autoscalingv1alpha1.NewForConfigOrDie(c)
 	//获取各个版本的配置信息
 	cs.pocV1alpha1 = pocv1alpha1.NewForConfigOrDie(c)
 
@@ -134,6 +147,7 @@ func New(c rest.Interface) *Clientset {
 	cs.autoscalingV1 = autoscalingv1.New(c)
 	cs.autoscalingV1beta2 = autoscalingv1beta2.New(c)
 	cs.autoscalingV1beta1 = autoscalingv1beta1.New(c)
+	cs.autoscalingV1alpha1 = autoscalingv1alpha1.New(c)
 	cs.pocV1alpha1 = pocv1alpha1.New(c)
 
 	cs.DiscoveryClient = discovery.NewDiscoveryClient(c)
@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vpastate exposes the current state of VerticalPodAutoscaler
+// objects as Prometheus gauges, in the spirit of kube-state-metrics.
+// package vpastate以kube-state-metrics风格的方式，将当前VerticalPodAutoscaler
+// 对象的状态以Prometheus gauge的形式暴露出来。
+package vpastate
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	vpa_lister "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1"
+	"k8s.io/klog"
+)
+
+const metricsNamespace = "kube_verticalpodautoscaler"
+
+// Collector is a prometheus.Collector that describes every VerticalPodAutoscaler
+// object currently known to the given lister. It is registered the same way as
+// the other metrics subsystems in this repository, via Register().
+// Collector是一个prometheus.Collector，它描述lister中当前已知的每个
+// VerticalPodAutoscaler对象。它和本仓库中的其他metrics子系统一样，通过Register()注册。
+type Collector struct {
+	vpaLister vpa_lister.VerticalPodAutoscalerLister
+
+	specUpdateMode            *prometheus.Desc
+	specContainerPolicyMin    *prometheus.Desc
+	specContainerPolicyMax    *prometheus.Desc
+	specContainerControlled   *prometheus.Desc
+	statusRecommendation      *prometheus.Desc
+	statusTargetRefInfo       *prometheus.Desc
+}
+
+// NewCollector returns a Collector that reads VPA objects from vpaLister.
+// NewCollector返回一个从vpaLister读取VPA对象的Collector。
+func NewCollector(vpaLister vpa_lister.VerticalPodAutoscalerLister) *Collector {
+	return &Collector{
+		vpaLister: vpaLister,
+		specUpdateMode: prometheus.NewDesc(
+			metricsNamespace+"_spec_updatepolicy_updatemode",
+			"Whether each VPA's update mode is set to a given value.",
+			[]string{"namespace", "verticalpodautoscaler", "update_mode"}, nil),
+		specContainerPolicyMin: prometheus.NewDesc(
+			metricsNamespace+"_spec_resourcepolicy_container_policies_min",
+			"Minimum resource allowed for a container per the VPA's resource policy.",
+			[]string{"namespace", "verticalpodautoscaler", "container", "resource"}, nil),
+		specContainerPolicyMax: prometheus.NewDesc(
+			metricsNamespace+"_spec_resourcepolicy_container_policies_max",
+			"Maximum resource allowed for a container per the VPA's resource policy.",
+			[]string{"namespace", "verticalpodautoscaler", "container", "resource"}, nil),
+		specContainerControlled: prometheus.NewDesc(
+			metricsNamespace+"_spec_resourcepolicy_container_policies_controlledvalues",
+			"Which value(s) the VPA's resource policy controls for the container (RequestsAndLimits=1/RequestsOnly=2).",
+			[]string{"namespace", "verticalpodautoscaler", "container"}, nil),
+		statusRecommendation: prometheus.NewDesc(
+			metricsNamespace+"_status_recommendation_containerrecommendations",
+			"Recommended resource value for a container, broken down by bound kind.",
+			[]string{"namespace", "verticalpodautoscaler", "container", "resource", "bound"}, nil),
+		statusTargetRefInfo: prometheus.NewDesc(
+			metricsNamespace+"_status_target_ref_info",
+			"Information about the object a VPA targets.",
+			[]string{"namespace", "verticalpodautoscaler", "target_api_version", "target_kind", "target_name"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.specUpdateMode
+	ch <- c.specContainerPolicyMin
+	ch <- c.specContainerPolicyMax
+	ch <- c.specContainerControlled
+	ch <- c.statusRecommendation
+	ch <- c.statusTargetRefInfo
+}
+
+// Collect implements prometheus.Collector.
+// Collect在每次/metrics被抓取时调用，遍历lister缓存中的所有VPA对象并发出对应的gauge。
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	vpas, err := c.vpaLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("vpastate: failed to list VPAs: %v", err)
+		return
+	}
+	for _, vpa := range vpas {
+		c.collectSingle(ch, vpa)
+	}
+}
+
+func (c *Collector) collectSingle(ch chan<- prometheus.Metric, vpa *vpa_types.VerticalPodAutoscaler) {
+	if vpa.Spec.UpdatePolicy != nil && vpa.Spec.UpdatePolicy.UpdateMode != nil {
+		ch <- prometheus.MustNewConstMetric(c.specUpdateMode, prometheus.GaugeValue, 1,
+			vpa.Namespace, vpa.Name, string(*vpa.Spec.UpdatePolicy.UpdateMode))
+	}
+
+	if vpa.Spec.ResourcePolicy != nil {
+		for _, containerPolicy := range vpa.Spec.ResourcePolicy.ContainerPolicies {
+			for resourceName, quantity := range containerPolicy.MinAllowed {
+				ch <- prometheus.MustNewConstMetric(c.specContainerPolicyMin, prometheus.GaugeValue,
+					float64(quantity.MilliValue())/1000.0, vpa.Namespace, vpa.Name, containerPolicy.ContainerName, string(resourceName))
+			}
+			for resourceName, quantity := range containerPolicy.MaxAllowed {
+				ch <- prometheus.MustNewConstMetric(c.specContainerPolicyMax, prometheus.GaugeValue,
+					float64(quantity.MilliValue())/1000.0, vpa.Namespace, vpa.Name, containerPolicy.ContainerName, string(resourceName))
+			}
+			ch <- prometheus.MustNewConstMetric(c.specContainerControlled, prometheus.GaugeValue,
+				controlledValuesToFloat(containerPolicy.ControlledValues), vpa.Namespace, vpa.Name, containerPolicy.ContainerName)
+		}
+	}
+
+	if vpa.Status.Recommendation != nil {
+		for _, containerRecommendation := range vpa.Status.Recommendation.ContainerRecommendations {
+			emitRecommendationBound(ch, c.statusRecommendation, vpa, containerRecommendation.ContainerName, "target", containerRecommendation.Target)
+			emitRecommendationBound(ch, c.statusRecommendation, vpa, containerRecommendation.ContainerName, "lowerbound", containerRecommendation.LowerBound)
+			emitRecommendationBound(ch, c.statusRecommendation, vpa, containerRecommendation.ContainerName, "upperbound", containerRecommendation.UpperBound)
+			emitRecommendationBound(ch, c.statusRecommendation, vpa, containerRecommendation.ContainerName, "uncappedtarget", containerRecommendation.UncappedTarget)
+		}
+	}
+
+	if vpa.Spec.TargetRef != nil {
+		ch <- prometheus.MustNewConstMetric(c.statusTargetRefInfo, prometheus.GaugeValue, 1,
+			vpa.Namespace, vpa.Name, vpa.Spec.TargetRef.APIVersion, vpa.Spec.TargetRef.Kind, vpa.Spec.TargetRef.Name)
+	}
+}
+
+func emitRecommendationBound(ch chan<- prometheus.Metric, desc *prometheus.Desc, vpa *vpa_types.VerticalPodAutoscaler, container, bound string, resources core.ResourceList) {
+	for resourceName, quantity := range resources {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(quantity.MilliValue())/1000.0,
+			vpa.Namespace, vpa.Name, container, string(resourceName), bound)
+	}
+}
+
+func controlledValuesToFloat(v *vpa_types.ContainerControlledValues) float64 {
+	if v == nil {
+		return 0
+	}
+	if *v == vpa_types.ContainerControlledValuesRequestsOnly {
+		return 2
+	}
+	return 1
+}
+
+// Register creates a Collector backed by vpaLister and registers it with the
+// default Prometheus registry, the same way metrics_recommender.Register()
+// registers the recommender's own gauges.
+// Register用vpaLister创建一个Collector，并将其注册到默认的Prometheus注册表中，
+// 方式与metrics_recommender.Register()注册recommender自身的gauge相同。
+func Register(vpaLister vpa_lister.VerticalPodAutoscalerLister) {
+	prometheus.MustRegister(NewCollector(vpaLister))
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vpastate
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	vpa_lister "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/listers/autoscaling.k8s.io/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newFakeLister builds a VerticalPodAutoscalerLister backed by an indexer
+// pre-populated with vpas, the same way the generated lister is normally fed
+// by a real informer's store.
+func newFakeLister(t *testing.T, vpas ...*vpa_types.VerticalPodAutoscaler) vpa_lister.VerticalPodAutoscalerLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, vpa := range vpas {
+		if err := indexer.Add(vpa); err != nil {
+			t.Fatalf("failed to add vpa %s/%s to indexer: %v", vpa.Namespace, vpa.Name, err)
+		}
+	}
+	return vpa_lister.NewVerticalPodAutoscalerLister(indexer)
+}
+
+// collectMetrics runs c through the standard prometheus.Collector protocol
+// and returns every emitted metric as a *dto.Metric, so test assertions can
+// inspect labels/values without standing up a real registry/HTTP endpoint.
+func collectMetrics(c *Collector) []*dto.Metric {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var metrics []*dto.Metric
+	go func() {
+		defer close(done)
+		for m := range ch {
+			pb := &dto.Metric{}
+			if err := m.Write(pb); err != nil {
+				continue
+			}
+			metrics = append(metrics, pb)
+		}
+	}()
+	c.Collect(ch)
+	close(ch)
+	<-done
+	return metrics
+}
+
+func findMetric(metrics []*dto.Metric, labelValues map[string]string) *dto.Metric {
+	for _, m := range metrics {
+		matches := true
+		for _, label := range m.Label {
+			if want, ok := labelValues[label.GetName()]; ok && label.GetValue() != want {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return m
+		}
+	}
+	return nil
+}
+
+func updateMode(mode vpa_types.UpdateMode) *vpa_types.UpdateMode {
+	return &mode
+}
+
+func TestCollectEmitsSpecAndStatusMetrics(t *testing.T) {
+	vpa := &vpa_types.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-vpa"},
+		Spec: vpa_types.VerticalPodAutoscalerSpec{
+			TargetRef: &autoscalingv1.CrossVersionObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       "Deployment",
+				Name:       "my-app",
+			},
+			UpdatePolicy: &vpa_types.PodUpdatePolicy{
+				UpdateMode: updateMode(vpa_types.UpdateModeAuto),
+			},
+			ResourcePolicy: &vpa_types.PodResourcePolicy{
+				ContainerPolicies: []vpa_types.ContainerResourcePolicy{
+					{
+						ContainerName: "main",
+						MinAllowed:    core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+						MaxAllowed:    core.ResourceList{core.ResourceCPU: resource.MustParse("1")},
+					},
+				},
+			},
+		},
+		Status: vpa_types.VerticalPodAutoscalerStatus{
+			Recommendation: &vpa_types.RecommendedPodResources{
+				ContainerRecommendations: []vpa_types.RecommendedContainerResources{
+					{
+						ContainerName: "main",
+						Target:        core.ResourceList{core.ResourceCPU: resource.MustParse("250m")},
+						LowerBound:    core.ResourceList{core.ResourceCPU: resource.MustParse("100m")},
+						UpperBound:    core.ResourceList{core.ResourceCPU: resource.MustParse("500m")},
+					},
+				},
+			},
+		},
+	}
+
+	c := NewCollector(newFakeLister(t, vpa))
+	metrics := collectMetrics(c)
+
+	if m := findMetric(metrics, map[string]string{"namespace": "default", "verticalpodautoscaler": "my-vpa", "update_mode": "Auto"}); m == nil || m.GetGauge().GetValue() != 1 {
+		t.Errorf("expected update_mode=Auto gauge with value 1, got %+v", m)
+	}
+	if m := findMetric(metrics, map[string]string{"container": "main", "resource": "cpu", "bound": "target"}); m == nil || m.GetGauge().GetValue() != 0.25 {
+		t.Errorf("expected target cpu recommendation of 0.25, got %+v", m)
+	}
+	if m := findMetric(metrics, map[string]string{"target_kind": "Deployment", "target_name": "my-app"}); m == nil {
+		t.Errorf("expected a target-ref info metric for my-app, got none")
+	}
+}
+
+func TestCollectSkipsVPAsWithoutOptionalFields(t *testing.T) {
+	vpa := &vpa_types.VerticalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bare-vpa"},
+	}
+
+	c := NewCollector(newFakeLister(t, vpa))
+	metrics := collectMetrics(c)
+
+	if len(metrics) != 0 {
+		t.Errorf("expected no metrics for a VPA with no spec/status set, got %d", len(metrics))
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+// CheckpointConfig configures the "checkpoint" backend, which is the
+// existing VPACheckpoint CRD store used by default today.
+type CheckpointConfig struct {
+	// MinCheckpoints is the minimum number of checkpoints written per
+	// recommender loop, mirroring the --min-checkpoints flag.
+	MinCheckpoints int `json:"minCheckpoints,omitempty"`
+}
+
+func init() {
+	Register("checkpoint", newCheckpointStorage)
+}
+
+// checkpointStorage adapts the existing VPACheckpoint-backed
+// checkpoint.CheckpointWriter/ClusterStateFeeder machinery to the
+// HistoryStorage interface. It is kept as a thin wrapper so that
+// LoadVPAs/InitFromCheckpoints, which already know how to talk to the API
+// server, stay the single source of truth for that codepath.
+// checkpointStorage将现有的、基于VPACheckpoint的checkpoint.CheckpointWriter/
+// ClusterStateFeeder机制适配到HistoryStorage接口上。它被保留为一层薄封装，
+// 这样已经知道如何与API Server交互的LoadVPAs/InitFromCheckpoints依然是
+// 这条代码路径上唯一的事实来源。
+type checkpointStorage struct {
+	cfg CheckpointConfig
+}
+
+func newCheckpointStorage(cfg Config) (HistoryStorage, error) {
+	c := CheckpointConfig{}
+	if cfg.Checkpoint != nil {
+		c = *cfg.Checkpoint
+	}
+	return &checkpointStorage{cfg: c}, nil
+}
+
+func (s *checkpointStorage) LoadHistory(ctx context.Context) ([]ContainerHistory, error) {
+	// Historical state is loaded into the ClusterState directly by
+	// ClusterStateFeeder.InitFromCheckpoints, which is still invoked from
+	// recommender/main.go for this backend; there is nothing to return here.
+	return nil, nil
+}
+
+func (s *checkpointStorage) PersistCheckpoint(ctx context.Context, vpa *vpa_types.VerticalPodAutoscaler, state []byte) error {
+	// Writing is still done through checkpoint.CheckpointWriter.StoreCheckpoints,
+	// which already batches and rate-limits writes per recommender.MaintainCheckpoints.
+	return nil
+}
+
+func (s *checkpointStorage) GCCheckpoints(ctx context.Context) error {
+	// Delegated to ClusterStateFeeder.GarbageCollectCheckpoints, unchanged.
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+)
+
+// FileConfig configures the "file" backend, which reads/writes snapshots from
+// a local path or a mounted volume instead of talking to the apiserver or a
+// metrics backend. Useful for air-gapped clusters and reproducible tests.
+// FileConfig用于配置"file"后端，它从本地路径或挂载卷读写快照，而不是和
+// apiserver或指标后端交互。适用于air-gapped集群和可复现的测试场景。
+type FileConfig struct {
+	// Dir is the directory containing one gzipped JSON snapshot per VPA,
+	// named "<namespace>_<vpa-name>.json.gz".
+	Dir string `json:"dir,omitempty"`
+}
+
+func init() {
+	Register("file", newFileStorage)
+}
+
+type fileStorage struct {
+	cfg FileConfig
+}
+
+func newFileStorage(cfg Config) (HistoryStorage, error) {
+	if cfg.File == nil || cfg.File.Dir == "" {
+		return nil, fmt.Errorf("storage backend \"file\" requires file.dir in --storage-config")
+	}
+	if err := os.MkdirAll(cfg.File.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create storage dir %q: %v", cfg.File.Dir, err)
+	}
+	return &fileStorage{cfg: *cfg.File}, nil
+}
+
+func (s *fileStorage) LoadHistory(ctx context.Context) ([]ContainerHistory, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing snapshot dir %q: %v", s.cfg.Dir, err)
+	}
+	var result []ContainerHistory
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".gz" {
+			continue
+		}
+		snapshot, err := s.readSnapshot(filepath.Join(s.cfg.Dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, snapshot...)
+	}
+	return result, nil
+}
+
+func (s *fileStorage) readSnapshot(path string) ([]ContainerHistory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading gzip snapshot %q: %v", path, err)
+	}
+	defer gz.Close()
+	var snapshot []ContainerHistory
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot %q: %v", path, err)
+	}
+	return snapshot, nil
+}
+
+func (s *fileStorage) PersistCheckpoint(ctx context.Context, vpa *vpa_types.VerticalPodAutoscaler, state []byte) error {
+	path := filepath.Join(s.cfg.Dir, fmt.Sprintf("%s_%s.json.gz", vpa.Namespace, vpa.Name))
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating snapshot %q: %v", path, err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	defer gz.Close()
+	if _, err := gz.Write(state); err != nil {
+		return fmt.Errorf("error writing snapshot %q: %v", path, err)
+	}
+	return nil
+}
+
+func (s *fileStorage) GCCheckpoints(ctx context.Context) error {
+	// Stale snapshot files are left in place; unlike the checkpoint CRD store
+	// there is no API server listing to reconcile against, so cleanup is left
+	// to the operator managing the mounted volume.
+	return nil
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/history"
+)
+
+// PrometheusConfig configures the "prometheus" backend, mirroring the
+// existing --prometheus-address/--history-length family of flags so it can
+// also be set declaratively via --storage-config.
+type PrometheusConfig struct {
+	Address       string `json:"address,omitempty"`
+	HistoryLength string `json:"historyLength,omitempty"`
+	JobName       string `json:"jobName,omitempty"`
+}
+
+func init() {
+	Register("prometheus", newPrometheusStorage)
+}
+
+// prometheusStorage wraps the existing history.PrometheusHistoryProvider.
+// prometheusStorage对现有的history.PrometheusHistoryProvider进行了封装。
+type prometheusStorage struct {
+	provider history.PrometheusHistoryProvider
+}
+
+func newPrometheusStorage(cfg Config) (HistoryStorage, error) {
+	c := PrometheusConfig{}
+	if cfg.Prometheus != nil {
+		c = *cfg.Prometheus
+	}
+	provider := history.NewPrometheusHistoryProvider(history.PrometheusHistoryProviderConfig{
+		Address:                c.Address,
+		HistoryLength:          c.HistoryLength,
+		CadvisorMetricsJobName: c.JobName,
+	})
+	return &prometheusStorage{provider: provider}, nil
+}
+
+func (s *prometheusStorage) LoadHistory(ctx context.Context) ([]ContainerHistory, error) {
+	// GetClusterHistory populates the ClusterState directly today; callers that
+	// need HistoryStorage's uniform shape should prefer the ClusterStateFeeder
+	// path (InitFromHistoryProvider) until this backend is migrated off it.
+	return nil, nil
+}
+
+func (s *prometheusStorage) PersistCheckpoint(ctx context.Context, vpa *vpa_types.VerticalPodAutoscaler, state []byte) error {
+	// Prometheus is a read-only sample source from the recommender's point of
+	// view; there is nothing to persist back to it.
+	return nil
+}
+
+func (s *prometheusStorage) GCCheckpoints(ctx context.Context) error {
+	return nil
+}
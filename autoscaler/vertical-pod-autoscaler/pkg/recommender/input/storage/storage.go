@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package storage defines the pluggable HistoryStorage interface used by the
+// recommender to load historical container samples and persist/garbage
+// collect checkpoints, plus a registry so that backends - built in or
+// third-party - can be selected by name via the --storage flag.
+// storage包定义了可插拔的HistoryStorage接口，recommender用它来加载历史容器样本，
+// 以及持久化/回收checkpoints；同时提供了一个registry，使得内置或第三方的后端都可以
+// 通过--storage参数按名字选择。
+package storage
+
+import (
+	"context"
+	"fmt"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// ContainerHistory is one container's historical usage samples, as loaded from
+// whatever backing store a HistoryStorage implementation uses.
+type ContainerHistory struct {
+	ContainerID model.ContainerID
+	Samples     []model.ContainerUsageSample
+}
+
+// HistoryStorage abstracts away where historical samples and VPA checkpoints
+// come from/go to, so the recommender's main loop doesn't need to know
+// whether it's talking to Prometheus, VPA CRD checkpoints, Thanos or a flat
+// file. 抽象出历史样本和VPA checkpoints的来源/去处，这样recommender的主循环就不需要
+// 知道自己究竟是在和Prometheus、VPA CRD checkpoints、Thanos还是一个普通文件打交道。
+type HistoryStorage interface {
+	// LoadHistory returns historical per-container samples used to seed the
+	// in-memory ClusterState on startup.
+	LoadHistory(ctx context.Context) ([]ContainerHistory, error)
+	// PersistCheckpoint writes out the current aggregated state for vpa so it
+	// can be recovered by a future LoadHistory call.
+	PersistCheckpoint(ctx context.Context, vpa *vpa_types.VerticalPodAutoscaler, state []byte) error
+	// GCCheckpoints removes checkpoints that no longer correspond to a VPA
+	// object known to the cluster.
+	GCCheckpoints(ctx context.Context) error
+}
+
+// Config is the declarative, per-backend configuration loaded from the file
+// named by --storage-config. Only the section matching the selected backend
+// is consulted; the rest is ignored, so a single file can describe every
+// backend an operator might switch between.
+// Config是从--storage-config指定的文件中加载的、按后端区分的声明式配置。
+// 只有与所选后端名字匹配的那一段会被读取，其余部分会被忽略，因此同一个文件可以
+// 描述运营商可能切换使用的所有后端。
+type Config struct {
+	Checkpoint *CheckpointConfig `json:"checkpoint,omitempty"`
+	Prometheus *PrometheusConfig `json:"prometheus,omitempty"`
+	Thanos     *ThanosConfig     `json:"thanos,omitempty"`
+	File       *FileConfig       `json:"file,omitempty"`
+}
+
+// Factory builds a HistoryStorage from the relevant section of Config.
+type Factory func(cfg Config) (HistoryStorage, error)
+
+var registry = map[string]Factory{}
+
+// Register adds a named backend to the registry. It is expected to be called
+// from an init() function in the package implementing the backend, mirroring
+// how third parties are meant to compile in additional backends.
+// Register将一个命名的后端加入到registry中。它应当在实现该后端的包的init()函数中
+// 被调用，这也是第三方编译进额外后端的预期方式。
+func Register(name string, factory Factory) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("storage backend %q already registered", name))
+	}
+	registry[name] = factory
+}
+
+// New builds the HistoryStorage registered under name, or an error if name is
+// unknown. NewForConfig构建注册在name下的HistoryStorage，如果name未知则返回错误。
+func New(name string, cfg Config) (HistoryStorage, error) {
+	factory, found := registry[name]
+	if !found {
+		return nil, fmt.Errorf("unknown storage backend %q, known backends: %v", name, knownBackends())
+	}
+	return factory(cfg)
+}
+
+func knownBackends() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	return names
+}
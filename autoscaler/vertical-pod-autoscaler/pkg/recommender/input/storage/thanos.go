@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// ThanosConfig configures the "thanos" backend, which queries a Thanos
+// Querier endpoint instead of a single Prometheus server, enabling
+// longer-range history than a single Prometheus's local retention allows.
+// ThanosConfig用于配置"thanos"后端，它查询的是Thanos Querier端点而非单个
+// Prometheus server，从而可以获取比单个Prometheus本地保留期更长的历史数据。
+type ThanosConfig struct {
+	QuerierAddress string `json:"querierAddress,omitempty"`
+	HistoryLength  string `json:"historyLength,omitempty"`
+	// StepDuration is passed to Thanos as the downsampling hint (the
+	// `step` query parameter); larger values trade resolution for range.
+	StepDuration string `json:"stepDuration,omitempty"`
+}
+
+func init() {
+	Register("thanos", newThanosStorage)
+}
+
+// thanosStorage runs PromQL range queries against a Thanos Querier, which
+// transparently fans out to short- and long-term stores and downsamples
+// older series, letting --history-length safely exceed a single
+// Prometheus's retention window.
+// thanosStorage向Thanos Querier发起PromQL range查询，Querier会透明地向
+// 短期和长期存储分别查询并对较旧的series做降采样，这样--history-length
+// 就可以安全地超过单个Prometheus的保留窗口。
+type thanosStorage struct {
+	cfg        ThanosConfig
+	httpClient *http.Client
+}
+
+func newThanosStorage(cfg Config) (HistoryStorage, error) {
+	if cfg.Thanos == nil || cfg.Thanos.QuerierAddress == "" {
+		return nil, fmt.Errorf("storage backend \"thanos\" requires thanos.querierAddress in --storage-config")
+	}
+	return &thanosStorage{cfg: *cfg.Thanos, httpClient: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// thanosQueries are the PromQL range queries run against the Querier, one per
+// resource this recommender tracks. Each must return a vector labelled with
+// at least namespace/pod/container, the same labels cadvisor attaches.
+var thanosQueries = map[model.ResourceName]string{
+	model.ResourceCPU:    `rate(container_cpu_usage_seconds_total{container!="", container!="POD"}[5m])`,
+	model.ResourceMemory: `container_memory_working_set_bytes{container!="", container!="POD"}`,
+}
+
+func (s *thanosStorage) LoadHistory(ctx context.Context) ([]ContainerHistory, error) {
+	historyLength, err := time.ParseDuration(s.cfg.HistoryLength)
+	if err != nil {
+		return nil, fmt.Errorf("invalid thanos.historyLength %q: %v", s.cfg.HistoryLength, err)
+	}
+	step := 5 * time.Minute
+	if s.cfg.StepDuration != "" {
+		step, err = time.ParseDuration(s.cfg.StepDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thanos.stepDuration %q: %v", s.cfg.StepDuration, err)
+		}
+	}
+
+	end := time.Now()
+	start := end.Add(-historyLength)
+
+	samplesByContainer := make(map[model.ContainerID][]model.ContainerUsageSample)
+	for resourceName, query := range thanosQueries {
+		matrix, err := s.queryRange(ctx, query, start, end, step)
+		if err != nil {
+			return nil, fmt.Errorf("thanos range query for %s failed: %v", resourceName, err)
+		}
+		for _, series := range matrix {
+			containerID, ok := containerIDFromLabels(series.Metric)
+			if !ok {
+				continue
+			}
+			for _, value := range series.Values {
+				amount, err := parseResourceAmount(resourceName, value.value)
+				if err != nil {
+					continue
+				}
+				samplesByContainer[containerID] = append(samplesByContainer[containerID], model.ContainerUsageSample{
+					MeasureStart: value.timestamp,
+					Usage:        amount,
+					Resource:     resourceName,
+				})
+			}
+		}
+	}
+
+	histories := make([]ContainerHistory, 0, len(samplesByContainer))
+	for containerID, samples := range samplesByContainer {
+		histories = append(histories, ContainerHistory{ContainerID: containerID, Samples: samples})
+	}
+	return histories, nil
+}
+
+func (s *thanosStorage) PersistCheckpoint(ctx context.Context, vpa *vpa_types.VerticalPodAutoscaler, state []byte) error {
+	return nil
+}
+
+func (s *thanosStorage) GCCheckpoints(ctx context.Context) error {
+	return nil
+}
+
+// containerIDFromLabels builds a model.ContainerID from a Thanos/Prometheus
+// series' labels, following cadvisor's namespace/pod/container label names.
+// It reports false if any of the three labels is missing.
+func containerIDFromLabels(labels map[string]string) (model.ContainerID, bool) {
+	namespace, pod, container := labels["namespace"], labels["pod"], labels["container"]
+	if namespace == "" || pod == "" || container == "" {
+		return model.ContainerID{}, false
+	}
+	return model.ContainerID{
+		PodID:         model.PodID{Namespace: namespace, PodName: pod},
+		ContainerName: container,
+	}, true
+}
+
+// parseResourceAmount converts a PromQL sample value (CPU cores, memory
+// bytes) into the recommender's internal ResourceAmount unit for
+// resourceName - cores to millicores for CPU, bytes unchanged for memory.
+func parseResourceAmount(resourceName model.ResourceName, value float64) (model.ResourceAmount, error) {
+	switch resourceName {
+	case model.ResourceCPU:
+		return model.ResourceAmount(value * 1000.0), nil
+	case model.ResourceMemory:
+		return model.ResourceAmount(value), nil
+	default:
+		return 0, fmt.Errorf("unsupported resource %v", resourceName)
+	}
+}
+
+// rangeQuerySample is one (timestamp, value) pair as returned by Prometheus's
+// and Thanos's /api/v1/query_range - the wire format is [unixSeconds, "stringValue"].
+type rangeQuerySample struct {
+	timestamp time.Time
+	value     float64
+}
+
+func (s *rangeQuerySample) UnmarshalJSON(data []byte) error {
+	var raw [2]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	seconds, ok := raw[0].(float64)
+	if !ok {
+		return fmt.Errorf("unexpected timestamp type %T", raw[0])
+	}
+	valueStr, ok := raw[1].(string)
+	if !ok {
+		return fmt.Errorf("unexpected value type %T", raw[1])
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return fmt.Errorf("unparsable sample value %q: %v", valueStr, err)
+	}
+	s.timestamp = time.Unix(int64(seconds), 0)
+	s.value = value
+	return nil
+}
+
+type rangeQuerySeries struct {
+	Metric map[string]string  `json:"metric"`
+	Values []rangeQuerySample `json:"values"`
+}
+
+type rangeQueryResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string             `json:"resultType"`
+		Result     []rangeQuerySeries `json:"result"`
+	} `json:"data"`
+}
+
+// queryRange runs a PromQL range query against the Thanos Querier's
+// Prometheus-compatible HTTP API and returns the resulting matrix.
+func (s *thanosStorage) queryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]rangeQuerySeries, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/query_range", s.cfg.QuerierAddress)
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, queryURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var parsed rangeQueryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("cannot decode Thanos response: %v", err)
+	}
+	if parsed.Status != "success" {
+		return nil, fmt.Errorf("Thanos query failed: %s", parsed.Error)
+	}
+	return parsed.Data.Result, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logic
+
+import (
+	poc_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/poc.autoscaling.k8s.io/v1alpha1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+)
+
+// ApplyScalingSignals rewrites recommended so that any container whose
+// v1alpha1 ContainerResourcePolicy names a ScalingSignal container has its
+// target derived from that signal container's recommendation instead of its
+// own histogram, per its SidecarMode. Containers without a ScalingSignal are
+// left untouched, preserving today's "each container independently" behavior.
+// ApplyScalingSignals重写recommended，使得在v1alpha1 ContainerResourcePolicy中
+// 指定了ScalingSignal容器的那些容器，改为根据其SidecarMode，从信号容器的
+// recommendation而不是自己的直方图推导出target。没有设置ScalingSignal的容器
+// 则保持不变，从而维持现有"每个容器独立计算"的行为。
+func ApplyScalingSignals(recommended RecommendedPodResources, policies []poc_types.ContainerResourcePolicy) RecommendedPodResources {
+	for _, policy := range policies {
+		if policy.ScalingSignal == "" {
+			continue
+		}
+		signal, hasSignal := recommended[policy.ScalingSignal]
+		dependent, hasDependent := recommended[policy.ContainerName]
+		if !hasSignal || !hasDependent {
+			continue
+		}
+		recommended[policy.ContainerName] = deriveFromSignal(dependent, signal, policy)
+	}
+	return recommended
+}
+
+func deriveFromSignal(dependent, signal RecommendedContainerResources, policy poc_types.ContainerResourcePolicy) RecommendedContainerResources {
+	mode := poc_types.SidecarModeProportional
+	if policy.SidecarMode != nil {
+		mode = *policy.SidecarMode
+	}
+	switch mode {
+	case poc_types.SidecarModeFixed:
+		// Keep the dependent container's own recommendation untouched; the
+		// per-container MinAllowed/MaxAllowed caps are still applied
+		// afterwards by the limitrange package, same as every other container.
+		return dependent
+	case poc_types.SidecarModeMirror:
+		return RecommendedContainerResources{
+			Target:     signal.Target,
+			LowerBound: signal.LowerBound,
+			UpperBound: signal.UpperBound,
+		}
+	default: // SidecarModeProportional
+		return RecommendedContainerResources{
+			Target:     model.ScaleResources(dependent.Target, signal.Target),
+			LowerBound: dependent.LowerBound,
+			UpperBound: dependent.UpperBound,
+		}
+	}
+}
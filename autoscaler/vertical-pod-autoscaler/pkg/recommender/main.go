@@ -17,15 +17,22 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
+	"io/ioutil"
 	"time"
 
 	"k8s.io/autoscaler/vertical-pod-autoscaler/common"
+	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/metrics/vpastate"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/history"
+	historystorage "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/storage"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/routines"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics"
 	metrics_quality "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/quality"
 	metrics_recommender "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/recommender"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 	"k8s.io/client-go/rest"
 	kube_flag "k8s.io/component-base/cli/flag"
 	"k8s.io/klog"
@@ -40,8 +47,9 @@ var (
 	kubeApiQps             = flag.Float64("kube-api-qps", 5.0, `QPS limit when making requests to Kubernetes apiserver`)
 	kubeApiBurst           = flag.Float64("kube-api-burst", 10.0, `QPS burst limit when making requests to Kubernetes apiserver`)
 
-	// 支持的存储模式有：prometheus和checkpoint(默认值)
-	storage = flag.String("storage", "", `Specifies storage mode. Supported values: prometheus, checkpoint (default)`)
+	// 支持的存储模式有：checkpoint(默认值)、prometheus、thanos、file，通过registry注册，第三方可编译进额外后端
+	storageBackend = flag.String("storage", "", `Specifies storage mode. Supported values: checkpoint (default), prometheus, thanos, file`)
+	storageConfig  = flag.String("storage-config", "", `Path to a JSON file with per-backend storage options (URLs, auth, retention). Only consulted for backends that need it (thanos, file).`)
 	// prometheus history provider configs
 	historyLength       = flag.String("history-length", "8d", `How much time back prometheus have to be queried to get historical metrics`)
 	podLabelPrefix      = flag.String("pod-label-prefix", "pod_label_", `Which prefix to look for pod labels in metrics`)
@@ -68,17 +76,31 @@ func main() {
 	metrics_recommender.Register()
 	metrics_quality.Register()
 
-	// 如果存储模式是prometheus，将useCheckpoints设为false，反之true
-	useCheckpoints := *storage != "prometheus"
+	// 将VPA对象以kube-state-metrics风格的gauge暴露出来，复用下面创建的同一个vpaLister
+	vpaLister := vpa_api_util.NewAllVpasLister(vpa_clientset.NewForConfigOrDie(config), make(chan struct{}))
+	vpastate.Register(vpaLister)
+
+	// 根据--storage选择的后端名字，从registry中构建对应的HistoryStorage
+	backendName := *storageBackend
+	if backendName == "" {
+		backendName = "checkpoint"
+	}
+	storageCfg := loadStorageConfig(*storageConfig)
+	hs, err := historystorage.New(backendName, storageCfg)
+	if err != nil {
+		klog.Fatalf("Failed to create storage backend %q: %v", backendName, err)
+	}
+
 	// 创建一个新的recommender实例
-	recommender := routines.NewRecommender(config, *checkpointsGCInterval, useCheckpoints)
-	if useCheckpoints {
+	recommender := routines.NewRecommender(config, *checkpointsGCInterval, backendName, hs)
+	switch backendName {
+	case "checkpoint":
 		// 如果存储模式为checkpoints，则调用InitFromCheckpoints将历史checkpoints加载到
 		// clusterState中
 		recommender.GetClusterStateFeeder().InitFromCheckpoints()
-	} else {
+	case "prometheus":
 		// prometheus的各项配置，如prometheus地址，查询历史长度等
-		config := history.PrometheusHistoryProviderConfig{
+		promConfig := history.PrometheusHistoryProviderConfig{
 			Address:                *prometheusAddress,
 			HistoryLength:          *historyLength,
 			PodLabelPrefix:         *podLabelPrefix,
@@ -91,7 +113,16 @@ func main() {
 			CadvisorMetricsJobName: *prometheusJobName,
 		}
 		// 如果存储模式为prometheus，调用InitFromHistoryProvider来通过历史提供者初始化VPA
-		recommender.GetClusterStateFeeder().InitFromHistoryProvider(history.NewPrometheusHistoryProvider(config))
+		recommender.GetClusterStateFeeder().InitFromHistoryProvider(history.NewPrometheusHistoryProvider(promConfig))
+	default:
+		// thanos/file以及第三方注册的后端通过统一的HistoryStorage.LoadHistory加载历史样本，
+		// 再喂给ClusterStateFeeder，和checkpoint/prometheus两个分支一样去初始化ClusterState
+		histories, err := hs.LoadHistory(context.Background())
+		if err != nil {
+			klog.Errorf("Failed to load history from storage backend %q: %v", backendName, err)
+		} else {
+			recommender.GetClusterStateFeeder().InitFromHistory(histories)
+		}
 	}
 
 	ticker := time.Tick(*metricsFetcherInterval)
@@ -106,6 +137,28 @@ func main() {
 
 }
 
+// loadStorageConfig reads the declarative --storage-config file, if any. An
+// empty path is not an error: backends that don't need extra configuration
+// (checkpoint, prometheus - which still has its own dedicated flags) work
+// fine with a zero-value Config.
+// loadStorageConfig读取--storage-config指定的声明式配置文件（如果有的话）。
+// 路径为空并不算错误：不需要额外配置的后端（checkpoint、仍然使用自己专属flag的
+// prometheus）在零值Config下也能正常工作。
+func loadStorageConfig(path string) historystorage.Config {
+	cfg := historystorage.Config{}
+	if path == "" {
+		return cfg
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		klog.Fatalf("Failed to read --storage-config %q: %v", path, err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		klog.Fatalf("Failed to parse --storage-config %q: %v", path, err)
+	}
+	return cfg
+}
+
 // 创建KubeConfig
 func createKubeConfig(kubeApiQps float32, kubeApiBurst int) *rest.Config {
 	config, err := rest.InClusterConfig()
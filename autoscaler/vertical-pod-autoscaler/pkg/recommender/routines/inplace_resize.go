@@ -0,0 +1,197 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package routines
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
+	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/klog"
+)
+
+var (
+	inPlaceResize = flag.Bool("in-place-resize", false,
+		`If set to true, the recommender will resize running pods of InPlace VPAs directly via the
+		resize subresource, instead of relying solely on the admission controller to apply the next
+		recommendation the pod is recreated or otherwise re-admitted. Only takes effect if the API
+		server advertises the resize subresource.`)
+	inPlaceResizeChangeThreshold = flag.Float64("in-place-resize-container-change-threshold", 0.10,
+		`Minimum fractional change, relative to a container's current resource request, needed before
+		the recommender resizes it in place. Mirrors the admission controller's flag of the same name,
+		so a recommendation that's already close enough to what's running doesn't churn the pod with a
+		resize for a negligible change.`)
+)
+
+// PodResizer applies a recommendation to an already-running pod via the
+// Kubernetes in-place pod vertical scaling "resize" subresource, instead of
+// going through the Updater's evict-and-recreate path. Only consulted for
+// VPAs whose UpdateMode is InPlace.
+// PodResizer通过Kubernetes原地pod垂直扩缩的"resize"子资源，把推荐值应用到一个
+// 已经在运行的pod上，而不是走Updater的驱逐重建路径。只有当VPA的UpdateMode为
+// InPlace时才会用到。
+type PodResizer interface {
+	ResizePod(ctx context.Context, podID model.PodID, resources vpa_types.RecommendedPodResources) error
+}
+
+type podResizer struct {
+	client          kube_client.Interface
+	changeThreshold float64
+}
+
+// NewPodResizer 创建一个基于resize子资源的PodResizer，changeThreshold见
+// --in-place-resize-container-change-threshold
+func NewPodResizer(client kube_client.Interface, changeThreshold float64) PodResizer {
+	return &podResizer{client: client, changeThreshold: changeThreshold}
+}
+
+// NewPodResizerIfSupported returns a PodResizer backed by client, or nil if
+// either --in-place-resize is false or the API server client talks to
+// doesn't advertise the pods/resize subresource (pre-1.27, or the feature
+// gate isn't enabled cluster-side). A nil PodResizer is a valid Recommender
+// dependency - RunOnce simply skips the in-place path for every VPA.
+// NewPodResizerIfSupported返回一个基于client的PodResizer；如果
+// --in-place-resize为false，或者client所连接的API server未宣告pods/resize
+// 子资源（1.27之前的版本，或者集群侧未启用该feature gate），则返回nil。nil的
+// PodResizer是Recommender的一个合法依赖——RunOnce只是会对每个VPA都跳过原地
+// 扩缩这条路径。
+func NewPodResizerIfSupported(client kube_client.Interface) PodResizer {
+	if !*inPlaceResize {
+		return nil
+	}
+	if !podResizeSubresourceSupported(client) {
+		klog.V(2).Infof("--in-place-resize is set but the API server does not advertise the resize subresource, falling back to Updater-driven eviction")
+		return nil
+	}
+	return NewPodResizer(client, *inPlaceResizeChangeThreshold)
+}
+
+// podResizeSubresourceSupported reports whether client talks to an API server
+// advertising the pods/resize subresource (available from Kubernetes 1.27+
+// with in-place pod vertical scaling). Falls back to false on any discovery
+// error, since that's the safe choice - it just means InPlace VPAs are left
+// to the Updater's evict-and-recreate path until the cluster is upgraded.
+// Mirrors pkg/admission-controller/main.go's podResizeSubresourceSupported;
+// the two main packages can't share an unexported helper, so this is kept as
+// its own small copy rather than a forced shared dependency.
+func podResizeSubresourceSupported(client kube_client.Interface) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		klog.Warningf("Failed to discover v1 API resources, assuming no in-place resize support: %v", err)
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "pods/resize" {
+			return true
+		}
+	}
+	return false
+}
+
+type containerResizePatch struct {
+	Name      string                    `json:"name"`
+	Resources core.ResourceRequirements `json:"resources"`
+}
+
+type podResizePatch struct {
+	Spec struct {
+		Containers []containerResizePatch `json:"containers"`
+	} `json:"spec"`
+}
+
+// ResizePod fetches the running pod identified by podID and, for every
+// container with a matching recommendation, issues a strategic-merge patch
+// against the resize subresource setting its Requests to the recommended
+// Target. Limits are left untouched, same as the admission controller's
+// normal proportional-limit handling.
+// ResizePod获取podID所标识的正在运行的pod，对于每一个有匹配recommendation的
+// 容器，向resize子资源发起strategic-merge patch，把它的Requests设为推荐的
+// Target。Limits保持不变，与admission controller正常的按比例处理limit一致。
+func (p *podResizer) ResizePod(ctx context.Context, podID model.PodID, resources vpa_types.RecommendedPodResources) error {
+	pod, err := p.client.CoreV1().Pods(podID.Namespace).Get(ctx, podID.PodName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to fetch pod %s/%s for in-place resize: %v", podID.Namespace, podID.PodName, err)
+	}
+
+	patch := podResizePatch{}
+	for _, container := range pod.Spec.Containers {
+		recommendation := vpa_utils.GetRecommendationForContainer(container.Name, &resources)
+		if recommendation == nil {
+			continue
+		}
+		if p.belowChangeThreshold(container, recommendation.Target) {
+			// recommendation is close enough to what's already running that
+			// resizing in place isn't worth the churn - 推荐值与当前运行值足够
+			// 接近，原地resize带来的扰动不值得
+			continue
+		}
+		patch.Spec.Containers = append(patch.Spec.Containers, containerResizePatch{
+			Name: container.Name,
+			Resources: core.ResourceRequirements{
+				Requests: recommendation.Target,
+				Limits:   container.Resources.Limits,
+			},
+		})
+	}
+	if len(patch.Spec.Containers) == 0 {
+		return nil
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.CoreV1().Pods(podID.Namespace).Patch(ctx, podID.PodName, apitypes.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "resize")
+	if err != nil {
+		return fmt.Errorf("failed to patch resize subresource for pod %s/%s: %v", podID.Namespace, podID.PodName, err)
+	}
+	klog.V(2).Infof("Resized pod %s/%s in place", podID.Namespace, podID.PodName)
+	return nil
+}
+
+// belowChangeThreshold reports whether target is within p.changeThreshold of
+// container's current request for every resource target sets, so a tiny
+// recommendation drift doesn't trigger a resize. Mirrors
+// pkg/admission-controller/logic/server.go's belowInPlaceResizeThreshold.
+// belowChangeThreshold表示对于target设置的每一种资源，它与container当前request
+// 的差距是否都在p.changeThreshold以内，从而避免微小的推荐值波动触发resize。
+// 与pkg/admission-controller/logic/server.go的belowInPlaceResizeThreshold一致。
+func (p *podResizer) belowChangeThreshold(container core.Container, target core.ResourceList) bool {
+	compared := false
+	for resourceName, recommended := range target {
+		current, hasCurrent := container.Resources.Requests[resourceName]
+		if !hasCurrent {
+			return false
+		}
+		request := math.Max(float64(current.MilliValue()), 1.0)
+		diff := math.Abs(request-float64(recommended.MilliValue())) / request
+		if diff >= p.changeThreshold {
+			return false
+		}
+		compared = true
+	}
+	return compared
+}
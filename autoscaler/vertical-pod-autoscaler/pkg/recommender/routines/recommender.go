@@ -22,16 +22,19 @@ import (
 	"time"
 
 	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	poc_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/poc.autoscaling.k8s.io/v1alpha1"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	vpa_api "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned/typed/autoscaling.k8s.io/v1"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/checkpoint"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/input/storage"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/logic"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/recommender/model"
 	metrics_recommender "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/recommender"
 	vpa_utils "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+	kube_client "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/klog"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -46,8 +49,14 @@ var (
 	minCheckpointsPerRun    = flag.Int("min-checkpoints", 10, "Minimum number of checkpoints to write per recommender's main loop")
 	// 如果设为true，则只记录有关联VPA的pods
 	memorySaver             = flag.Bool("memory-saver", false, `If true, only track pods which have an associated VPA`)
+	// 该recommender实例的名字，用于匹配VPA.Spec.Recommenders；空名字的VPA由名为"default"的recommender处理
+	recommenderName         = flag.String("recommender-name", DefaultRecommenderName, "Name of the recommender instance. Only VPA objects that either omit spec.recommenders or name this recommender are processed.")
 )
 
+// DefaultRecommenderName is the name used for VPA objects that don't set
+// spec.recommenders, and the default value of --recommender-name.
+const DefaultRecommenderName = "default"
+
 // Recommender根据从metrics api周期性得到的数据为指定容器建议资源
 type Recommender interface {
 	// RunOnce实现recommender在一次迭代中所需的工作
@@ -74,8 +83,13 @@ type recommender struct {
 	lastCheckpointGC              time.Time
 	vpaClient                     vpa_api.VerticalPodAutoscalersGetter
 	podResourceRecommender        logic.PodResourceRecommender
+	historyStorage                storage.HistoryStorage
 	useCheckpoints                bool
 	lastAggregateContainerStateGC time.Time
+	// podResizer应用InPlace模式VPA的推荐值，为nil时InPlace的VPA表现得和Recreate一样
+	podResizer PodResizer
+	// recommenderName是该recommender实例的名字，用来匹配VPA.Spec.Recommenders
+	recommenderName string
 }
 
 func (r *recommender) GetClusterState() *model.ClusterState {
@@ -93,6 +107,7 @@ func (r *recommender) UpdateVPAs() {
 	// 持续观察
 	defer cnt.Observe()
 
+	ownedVpas := 0
 	for _, observedVpa := range r.clusterState.ObservedVpas {
 		key := model.VpaID{
 			Namespace: observedVpa.Namespace,
@@ -102,7 +117,21 @@ func (r *recommender) UpdateVPAs() {
 		if !found {
 			continue
 		}
+		if !r.ownsVPA(observedVpa.Spec.Recommenders) {
+			// 该VPA指定了其他recommender，不由当前实例负责
+			continue
+		}
+		ownedVpas++
 		resources := r.podResourceRecommender.GetRecommendedPodResources(GetContainerNameToAggregateStateMap(vpa))
+		// 如果任意容器在其（经UpgradePodResourcePolicy从v1迁移来的）策略中设置了
+		// ScalingSignal，则让其recommendation改为从信号容器的recommendation推导，
+		// 而不是自己的直方图。
+		// If any container's (migrated from v1 via UpgradePodResourcePolicy)
+		// policy sets a ScalingSignal, derive its recommendation from the
+		// signal container's recommendation instead of its own histogram.
+		if upgradedPolicy := poc_types.UpgradePodResourcePolicy(observedVpa.Spec.ResourcePolicy); upgradedPolicy != nil {
+			resources = logic.ApplyScalingSignals(resources, upgradedPolicy.ContainerPolicies)
+		}
 		had := vpa.HasRecommendation()
 		// vap执行自身的更新建议函数 注意是Recommendation！
 		vpa.UpdateRecommendation(getCappedRecommendation(vpa.ID, resources, observedVpa.Spec.ResourcePolicy))
@@ -112,25 +141,57 @@ func (r *recommender) UpdateVPAs() {
 		hasMatchingPods := r.clusterState.VpasWithMatchingPods[vpa.ID]
 		vpa.UpdateConditions(hasMatchingPods)
 		if err := r.clusterState.RecordRecommendation(vpa, time.Now()); err != nil {
-			klog.Warningf("%v", err)
-			klog.V(4).Infof("VPA dump")
-			klog.V(4).Infof("%+v", vpa)
-			klog.V(4).Infof("HasMatchingPods: %v", hasMatchingPods)
+			klog.ErrorS(err, "Failed to record recommendation", "vpa", vpa.ID.VpaName, "namespace", vpa.ID.Namespace)
+			klog.V(4).InfoS("VPA dump", "vpa", vpa)
+			klog.V(4).InfoS("Matching pods state", "vpa", vpa.ID.VpaName, "hasMatchingPods", hasMatchingPods)
 			pods := r.clusterState.GetMatchingPods(vpa)
-			klog.V(4).Infof("MatchingPods: %+v", pods)
+			klog.V(4).InfoS("Matching pods", "vpa", vpa.ID.VpaName, "pods", pods)
 			if len(pods) > 0 != hasMatchingPods {
-				klog.Errorf("Aggregated states and matching pods disagree for vpa %v/%v", vpa.ID.Namespace, vpa.ID.VpaName)
+				klog.ErrorS(nil, "Aggregated states and matching pods disagree", "vpa", vpa.ID.VpaName, "namespace", vpa.ID.Namespace)
 			}
 		}
 		cnt.Add(vpa)
 
+		status := vpa.AsStatus()
 		_, err := vpa_utils.UpdateVpaStatusIfNeeded(
-			r.vpaClient.VerticalPodAutoscalers(vpa.ID.Namespace), vpa.ID.VpaName, vpa.AsStatus(), &observedVpa.Status)
+			r.vpaClient.VerticalPodAutoscalers(vpa.ID.Namespace), vpa.ID.VpaName, status, &observedVpa.Status)
 		if err != nil {
-			klog.Errorf(
-				"Cannot update VPA %v object. Reason: %+v", vpa.ID.VpaName, err)
+			klog.ErrorS(err, "Cannot update VPA object", "vpa", vpa.ID.VpaName, "namespace", vpa.ID.Namespace)
+		}
+
+		if r.podResizer != nil && status.Recommendation != nil && isInPlaceUpdateMode(observedVpa.Spec.UpdatePolicy) {
+			// 对于InPlace模式，除了写回VPA状态外，还要把推荐值原地应用到匹配的pod上，
+			// 而不是依赖Updater去驱逐重建它们。
+			for _, podID := range r.clusterState.GetMatchingPods(vpa) {
+				if err := r.podResizer.ResizePod(context.Background(), podID, *status.Recommendation); err != nil {
+					klog.ErrorS(err, "Failed to resize pod in place", "pod", podID, "vpa", vpa.ID.VpaName, "namespace", vpa.ID.Namespace, "recommenderName", r.recommenderName)
+				}
+			}
+		}
+	}
+	metrics_recommender.ObserveOwnedVPAs(r.recommenderName, ownedVpas)
+}
+
+func isInPlaceUpdateMode(policy *vpa_types.PodUpdatePolicy) bool {
+	return policy != nil && policy.UpdateMode != nil && *policy.UpdateMode == vpa_types.UpdateModeInPlace
+}
+
+// ownsVPA reports whether this recommender instance should process a VPA
+// that names recommenders in its spec. An empty list means "the default
+// recommender", matching the real VPA API's documented behavior.
+// ownsVPA判断当前recommender实例是否应该处理一个在spec中指定了recommenders的
+// VPA。空列表意味着"由default recommender处理"，这与真实VPA API所记录的行为
+// 一致。
+func (r *recommender) ownsVPA(recommenders []vpa_types.VerticalPodAutoscalerRecommenderSelector) bool {
+	if len(recommenders) == 0 {
+		return r.recommenderName == DefaultRecommenderName
+	}
+	for _, selector := range recommenders {
+		if selector.Name == r.recommenderName {
+			return true
 		}
 	}
+	return false
 }
 
 // getCappedRecommendation creates a recommendation based on recommended pod
@@ -169,6 +230,11 @@ func (r *recommender) MaintainCheckpoints(ctx context.Context, minCheckpointsPer
 		if time.Now().Sub(r.lastCheckpointGC) > r.checkpointsGCInterval {
 			r.lastCheckpointGC = now
 			r.clusterStateFeeder.GarbageCollectCheckpoints()
+			if r.historyStorage != nil {
+				if err := r.historyStorage.GCCheckpoints(ctx); err != nil {
+					klog.Warningf("Failed to garbage collect checkpoints in %T. Reason: %+v", r.historyStorage, err)
+				}
+			}
 		}
 	}
 
@@ -195,7 +261,7 @@ func (r *recommender) RunOnce() {
 	ctx, cancelFunc := context.WithDeadline(ctx, time.Now().Add(*checkpointsWriteTimeout))
 	defer cancelFunc()
 
-	klog.V(3).Infof("Recommender Run")
+	klog.V(3).InfoS("Recommender run starting", "recommenderName", r.recommenderName)
 
 	// 用VPAs的当前状态更新clusterState
 	r.clusterStateFeeder.LoadVPAs()
@@ -208,7 +274,7 @@ func (r *recommender) RunOnce() {
 	// 用当前容器的使用情况更新clusterState
 	r.clusterStateFeeder.LoadRealTimeMetrics()
 	timer.ObserveStep("LoadMetrics")
-	klog.V(3).Infof("ClusterState is tracking %v PodStates and %v VPAs", len(r.clusterState.Pods), len(r.clusterState.Vpas))
+	klog.V(3).InfoS("ClusterState updated", "podStates", len(r.clusterState.Pods), "vpas", len(r.clusterState.Vpas))
 
 	// 计算推荐值并把VPA状态更新送给API Server
 	r.UpdateVPAs()
@@ -222,7 +288,7 @@ func (r *recommender) RunOnce() {
 	// 移除没有匹配VPA的历史checkpoints
 	r.GarbageCollect()
 	timer.ObserveStep("GarbageCollect")
-	klog.V(3).Infof("ClusterState is tracking %d aggregated container states", r.clusterState.StateMapSize())
+	klog.V(3).InfoS("ClusterState aggregated container states", "count", r.clusterState.StateMapSize())
 }
 
 // RecommenderFactory用来创建Recommender实例
@@ -233,6 +299,9 @@ type RecommenderFactory struct {
 	CheckpointWriter       checkpoint.CheckpointWriter
 	PodResourceRecommender logic.PodResourceRecommender
 	VpaClient              vpa_api.VerticalPodAutoscalersGetter
+	HistoryStorage         storage.HistoryStorage
+	PodResizer             PodResizer
+	RecommenderName        string
 
 	CheckpointsGCInterval time.Duration
 	UseCheckpoints        bool
@@ -241,14 +310,21 @@ type RecommenderFactory struct {
 // Make 创建一个新的recommender实例
 // 可以为容器提供连续的资源推荐
 func (c RecommenderFactory) Make() Recommender {
+	name := c.RecommenderName
+	if name == "" {
+		name = DefaultRecommenderName
+	}
 	recommender := &recommender{
 		clusterState:                  c.ClusterState,
 		clusterStateFeeder:            c.ClusterStateFeeder,
 		checkpointWriter:              c.CheckpointWriter,
 		checkpointsGCInterval:         c.CheckpointsGCInterval,
 		useCheckpoints:                c.UseCheckpoints,
+		historyStorage:                c.HistoryStorage,
 		vpaClient:                     c.VpaClient,
 		podResourceRecommender:        c.PodResourceRecommender,
+		podResizer:                    c.PodResizer,
+		recommenderName:               name,
 		lastAggregateContainerStateGC: time.Now(),
 		lastCheckpointGC:              time.Now(),
 	}
@@ -259,7 +335,15 @@ func (c RecommenderFactory) Make() Recommender {
 
 // 创建一个新的recommender实例，自动创建相关依赖。
 // 不再建议使用，建议使用RecommenderFactory
-func NewRecommender(config *rest.Config, checkpointsGCInterval time.Duration, useCheckpoints bool) Recommender {
+//
+// historyStorage is the HistoryStorage backend selected by the --storage
+// flag (see pkg/recommender/input/storage); "checkpoint" is still treated
+// specially here because checkpoint reads/writes continue to go through
+// ClusterStateFeeder/CheckpointWriter rather than HistoryStorage itself.
+// historyStorage是--storage参数选择的HistoryStorage后端（见pkg/recommender/
+// input/storage）；"checkpoint"在这里仍被特殊对待，因为checkpoint的读写依旧
+// 通过ClusterStateFeeder/CheckpointWriter完成，而不是HistoryStorage本身。
+func NewRecommender(config *rest.Config, checkpointsGCInterval time.Duration, storageName string, historyStorage storage.HistoryStorage) Recommender {
 	clusterState := model.NewClusterState()
 	return RecommenderFactory{
 		ClusterState:           clusterState,
@@ -267,7 +351,10 @@ func NewRecommender(config *rest.Config, checkpointsGCInterval time.Duration, us
 		CheckpointWriter:       checkpoint.NewCheckpointWriter(clusterState, vpa_clientset.NewForConfigOrDie(config).AutoscalingV1()),
 		VpaClient:              vpa_clientset.NewForConfigOrDie(config).AutoscalingV1(),
 		PodResourceRecommender: logic.CreatePodResourceRecommender(),
+		HistoryStorage:         historyStorage,
+		PodResizer:             NewPodResizerIfSupported(kube_client.NewForConfigOrDie(config)),
+		RecommenderName:        *recommenderName,
 		CheckpointsGCInterval:  checkpointsGCInterval,
-		UseCheckpoints:         useCheckpoints,
+		UseCheckpoints:         storageName == "checkpoint" || storageName == "",
 	}.Make()
 }
@@ -0,0 +1,237 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package target
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	metrics_target "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/target"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+)
+
+const (
+	// dynamicInformerResync is the resync period for every per-GVR dynamic
+	// informer this cache creates, mirroring defaultResyncPeriod used for
+	// the well-known controller informers.
+	dynamicInformerResync = 10 * time.Minute
+	// gvrSweepInterval is how often the cache looks for GVRs that have gone
+	// unused for longer than their TTL.
+	gvrSweepInterval = time.Minute
+)
+
+// dynamicScaleCache lazily creates and caches one dynamic informer per GVR
+// that has a scale subresource, so repeated Fetch calls against the same
+// CRD kind are answered from a local, already-synced store instead of
+// issuing an API call each time. Entries for GVRs that haven't been looked
+// up in over ttl are evicted, so a burst of short-lived CRDs (e.g. from a
+// CI pipeline creating and deleting its own CRD instances) doesn't leave
+// informers running forever.
+// dynamicScaleCache会懒加载地为每一个拥有scale子资源的GVR创建并缓存一个dynamic
+// informer，这样对同一种CRD kind的重复Fetch调用就能从本地、已经同步好的store中
+//得到答案，而不必每次都发起一次API调用。超过ttl时间没有被查找过的GVR对应的
+// entry会被淘汰，这样一连串短生命周期的CRD（比如CI流水线创建又删除自己的CRD
+// 实例）就不会让informer永远运行下去。
+type dynamicScaleCache struct {
+	mu              sync.Mutex
+	dynamicClient   dynamic.Interface
+	discoveryClient discovery.DiscoveryInterface
+	mapper          apimeta.RESTMapper
+	ttl             time.Duration
+	entries         map[schema.GroupVersionResource]*scaleGVRCacheEntry
+	// scalableGVKs caches the outcome of hasScaleSubresource per GroupKind,
+	// since discovery is relatively expensive and a kind's ability to scale
+	// never changes at runtime.
+	scalableGVKs map[schema.GroupKind]bool
+}
+
+type scaleGVRCacheEntry struct {
+	informer cache.SharedIndexInformer
+	stopCh   chan struct{}
+	// ready is closed once informer has finished its initial sync (or given
+	// up trying to). Callers that find this entry already in the map wait on
+	// ready outside of dynamicScaleCache.mu, so a slow/stuck informer sync
+	// blocks only the callers waiting for that one GVR, not every other GVR
+	// sharing the cache.
+	ready        chan struct{}
+	lastAccessed time.Time
+}
+
+// newDynamicScaleCache returns a dynamicScaleCache backed by dynamicClient
+// and discoveryClient (used once per GroupKind to confirm it actually has a
+// scale subresource) and mapper (used to resolve a GroupKind to the GVR the
+// informer is built for). Call run to start the background eviction sweep.
+func newDynamicScaleCache(dynamicClient dynamic.Interface, discoveryClient discovery.DiscoveryInterface, mapper apimeta.RESTMapper, ttl time.Duration) *dynamicScaleCache {
+	return &dynamicScaleCache{
+		dynamicClient:   dynamicClient,
+		discoveryClient: discoveryClient,
+		mapper:          mapper,
+		ttl:             ttl,
+		entries:         make(map[schema.GroupVersionResource]*scaleGVRCacheEntry),
+		scalableGVKs:    make(map[schema.GroupKind]bool),
+	}
+}
+
+// run starts the background sweep that evicts GVRs unused for longer than
+// c.ttl. Intended to be called once, in a goroutine, right after the cache
+// is constructed.
+func (c *dynamicScaleCache) run(stopCh <-chan struct{}) {
+	wait.Until(c.evictStale, gvrSweepInterval, stopCh)
+}
+
+func (c *dynamicScaleCache) evictStale() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for gvr, entry := range c.entries {
+		if now.Sub(entry.lastAccessed) > c.ttl {
+			klog.V(4).Infof("evicting unused scale informer for %v", gvr)
+			close(entry.stopCh)
+			delete(c.entries, gvr)
+			metrics_target.SetScaleInformerCount(len(c.entries))
+		}
+	}
+}
+
+// getSelector returns the label selector read off status.selector of the
+// groupKind/namespace/name object, lazily starting (and caching) a dynamic
+// informer for its GVR on first use. Returns an error if groupKind has no
+// RESTMapping, has no scale subresource, or the object's status.selector
+// can't be parsed as a label selector.
+func (c *dynamicScaleCache) getSelector(groupKind schema.GroupKind, namespace, name string) (labels.Selector, error) {
+	if !c.hasScaleSubresource(groupKind) {
+		return nil, fmt.Errorf("%s does not have a scale subresource", groupKind)
+	}
+	mapping, err := c.mapper.RESTMapping(groupKind)
+	if err != nil {
+		return nil, err
+	}
+	gvr := mapping.Resource
+
+	informer, hit := c.informerFor(gvr)
+	if hit {
+		metrics_target.ObserveScaleCacheHit()
+	} else {
+		metrics_target.ObserveScaleCacheMiss()
+	}
+
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := informer.GetStore().GetByKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s %s does not exist", groupKind, key)
+	}
+	unstructuredObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T for %s %s", obj, groupKind, key)
+	}
+	selectorString, found, err := unstructured.NestedString(unstructuredObj.Object, "status", "selector")
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s %s has no status.selector", groupKind, key)
+	}
+	return labels.Parse(selectorString)
+}
+
+// informerFor returns the (possibly freshly started) informer for gvr,
+// along with whether it was already cached (a cache hit). The blocking
+// cache.WaitForCacheSync call for a freshly started informer happens outside
+// c.mu - holding the lock across it would stall every other GVR's lookups
+// (and eviction sweeps) for as long as this one CRD's informer takes to sync.
+func (c *dynamicScaleCache) informerFor(gvr schema.GroupVersionResource) (cache.SharedIndexInformer, bool) {
+	c.mu.Lock()
+	if entry, ok := c.entries[gvr]; ok {
+		entry.lastAccessed = time.Now()
+		c.mu.Unlock()
+		<-entry.ready
+		return entry.informer, true
+	}
+
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(c.dynamicClient, dynamicInformerResync, "", nil)
+	informer := factory.ForResource(gvr).Informer()
+	entry := &scaleGVRCacheEntry{
+		informer:     informer,
+		stopCh:       make(chan struct{}),
+		ready:        make(chan struct{}),
+		lastAccessed: time.Now(),
+	}
+	c.entries[gvr] = entry
+	metrics_target.SetScaleInformerCount(len(c.entries))
+	c.mu.Unlock()
+
+	go informer.Run(entry.stopCh)
+	if !cache.WaitForCacheSync(entry.stopCh, informer.HasSynced) {
+		klog.Errorf("could not sync dynamic informer for %v", gvr)
+	}
+	close(entry.ready)
+
+	return informer, false
+}
+
+// hasScaleSubresource reports whether groupKind's resource exposes a scale
+// subresource, consulting discovery once per GroupKind and caching the
+// result - a kind either has a scale subresource or it doesn't, for the
+// lifetime of the process.
+func (c *dynamicScaleCache) hasScaleSubresource(groupKind schema.GroupKind) bool {
+	c.mu.Lock()
+	if scalable, ok := c.scalableGVKs[groupKind]; ok {
+		c.mu.Unlock()
+		return scalable
+	}
+	c.mu.Unlock()
+
+	scalable := c.discoverScaleSubresource(groupKind)
+	c.mu.Lock()
+	c.scalableGVKs[groupKind] = scalable
+	c.mu.Unlock()
+	return scalable
+}
+
+func (c *dynamicScaleCache) discoverScaleSubresource(groupKind schema.GroupKind) bool {
+	mapping, err := c.mapper.RESTMapping(groupKind)
+	if err != nil {
+		return false
+	}
+	resources, err := c.discoveryClient.ServerResourcesForGroupVersion(mapping.Resource.GroupVersion().String())
+	if err != nil {
+		return false
+	}
+	scaleName := mapping.Resource.Resource + "/scale"
+	for _, r := range resources.APIResources {
+		if r.Name == scaleName {
+			return true
+		}
+	}
+	return false
+}
@@ -19,21 +19,31 @@ package main
 import (
 	"context"
 	"flag"
+	"os"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/common"
 	vpa_clientset "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/client/clientset/versioned"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/target"
 	updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/logic"
+	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/updater/priority"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/limitrange"
 	"k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics"
 	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
 	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
 	"k8s.io/client-go/informers"
 	kube_client "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	clientcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	kube_restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
 	kube_flag "k8s.io/component-base/cli/flag"
 	"k8s.io/klog"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 var (
@@ -59,6 +69,30 @@ var (
 
 	address = flag.String("address", ":8943", "The address to expose Prometheus metrics.")
 	// 普罗米修斯地址
+
+	inPlaceUpdateMode = flag.String("in-place-update-mode", string(priority.InPlaceUpdateModeDisabled),
+		`Whether updates may be applied via the pods/resize subresource instead of eviction:
+		Disabled (default), PreferInPlace (resize when possible, evict otherwise) or
+		InPlaceOnly (never evict, skip pods that would need a restart).`)
+	// 是否允许通过pods/resize子资源而不是驱逐来完成更新
+
+	leaderElect = flag.Bool("leader-elect", false,
+		`Start a leader election client and gain leadership before running the updater loop.
+		Enable this when running updater with more than one replica.`)
+	leaderElectLeaseDuration = flag.Duration("leader-elect-lease-duration", 15*time.Second,
+		`The duration that non-leader candidates will wait after observing a leadership
+		renewal until attempting to acquire leadership of the lease.`)
+	leaderElectRenewDeadline = flag.Duration("leader-elect-renew-deadline", 10*time.Second,
+		`The interval between attempts by the acting leader to renew its leadership before it stops leading.`)
+	leaderElectRetryPeriod = flag.Duration("leader-elect-retry-period", 2*time.Second,
+		`The duration the clients should wait between attempting acquisition and renewal of a leadership.`)
+	leaderElectResourceLock = flag.String("leader-elect-resource-lock", resourcelock.LeasesResourceLock,
+		`The type of resource object that is used for locking during leader election.`)
+	leaderElectResourceName = flag.String("leader-elect-resource-name", "vpa-updater",
+		`The name of resource object that is used for locking during leader election.`)
+	leaderElectResourceNamespace = flag.String("leader-elect-resource-namespace", "kube-system",
+		`The namespace of resource object that is used for locking during leader election.`)
+	// 以上七个参数用于配置updater的leader election，避免多副本部署时重复驱逐pod
 )
 
 const (
@@ -116,21 +150,109 @@ func main() {
 	}
 
 
+	// 第五点五步：构建集群压力信号，供优先级计算器在scale-down和scale-up的pod
+	// 之间做选择时参考。节点CPU和pending pod的lister都来自updater已经持有的
+	// factory；metricsClient另起一个连接，因为它访问的是metrics.k8s.io而不是
+	// 核心API组。
+	// Build the cluster pressure signals the priority calculator consults when
+	// choosing between scale-down and scale-up pods. Node CPU and pending pod
+	// listers both come from the factory the updater already holds;
+	// metricsClient is a separate connection since it talks to metrics.k8s.io
+	// rather than the core API group.
+	nodeLister := factory.Core().V1().Nodes().Lister()
+	podLister := factory.Core().V1().Pods().Lister()
+	pdbLister := factory.Policy().V1().PodDisruptionBudgets().Lister()
+	metricsClient := metricsclientset.NewForConfigOrDie(config)
+	stopCh := make(chan struct{})
+	factory.Start(stopCh)
+	factory.WaitForCacheSync(stopCh)
+
+	pressureSignals := priority.NewDefaultClusterPressureSignals(nodeLister, podLister, pdbLister, metricsClient)
+
 	// TODO: use SharedInformerFactory in updater
 	// 第六步：通过SharedInformerFactory创建updater资源类型（关键步骤）
-	updater, err := updater.NewUpdater(kubeClient, vpaClient, *minReplicas, *evictionRateLimit, *evictionRateBurst, *evictionToleranceFraction, vpa_api_util.NewCappingRecommendationProcessor(limitRangeCalculator), nil, targetSelectorFetcher)
+	updater, err := updater.NewUpdater(kubeClient, vpaClient, *minReplicas, *evictionRateLimit, *evictionRateBurst, *evictionToleranceFraction, vpa_api_util.NewCappingRecommendationProcessor(limitRangeCalculator), priority.NewQoSPriorityAdmission(), targetSelectorFetcher, priority.InPlaceUpdateMode(*inPlaceUpdateMode), pressureSignals...)
 	if err != nil {
 		klog.Fatalf("Failed to create updater: %v", err)
 	}
 
 	// 第七步：迭代时间 进行更新
-	ticker := time.Tick(*updaterInterval)
-	for range ticker {
-		ctx, cancel := context.WithTimeout(context.Background(), *updaterInterval)
-		defer cancel()
-		updater.RunOnce(ctx)
-		// 整个循环中单个迭代
-		healthCheck.UpdateLastActivity()
-		// 更新healthCheck
+	runUpdaterLoop := func(ctx context.Context) {
+		ticker := time.Tick(*updaterInterval)
+		for range ticker {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			tickCtx, cancel := context.WithTimeout(ctx, *updaterInterval)
+			updater.RunOnce(tickCtx)
+			// 整个循环中单个迭代
+			cancel()
+			healthCheck.UpdateLastActivity()
+			// 更新healthCheck
+		}
+	}
+
+	if !*leaderElect {
+		runUpdaterLoop(context.Background())
+		return
+	}
+
+	// 只有选举出的leader才会运行updater循环；standby副本仍然会对外提供
+	// /metrics和healthcheck，但不会参与驱逐/resize。
+	runAsLeaderElected(kubeClient, runUpdaterLoop)
+}
+
+// runAsLeaderElected blocks running the leader election client, invoking run
+// with a context that is cancelled as soon as this replica stops being the
+// leader. Standbys never call run - they keep serving /metrics and
+// healthchecks (already started in main, above) but skip the tick entirely.
+// runAsLeaderElected阻塞地运行leader election客户端，一旦该副本不再是leader，
+// 就会取消传给run的context。standby副本永远不会调用run——它们会继续提供
+// /metrics和healthcheck（已经在main的前面步骤中启动），但完全跳过tick。
+func runAsLeaderElected(kubeClient kube_client.Interface, run func(ctx context.Context)) {
+	id, err := os.Hostname()
+	if err != nil {
+		klog.Fatalf("Unable to get hostname: %v", err)
+	}
+	id = id + "_" + string(uuid.NewUUID())
+
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(&clientcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events(*leaderElectResourceNamespace)})
+	eventRecorder := eventBroadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "vpa-updater"})
+
+	lock, err := resourcelock.New(
+		*leaderElectResourceLock,
+		*leaderElectResourceNamespace,
+		*leaderElectResourceName,
+		kubeClient.CoreV1(),
+		kubeClient.CoordinationV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: eventRecorder,
+		},
+	)
+	if err != nil {
+		klog.Fatalf("Unable to create leader election lock: %v", err)
 	}
+
+	leaderelection.RunOrDie(context.Background(), leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: *leaderElectLeaseDuration,
+		RenewDeadline: *leaderElectRenewDeadline,
+		RetryPeriod:   *leaderElectRetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: run,
+			OnStoppedLeading: func() {
+				klog.Infof("%s stopped being the leader, exiting", id)
+				os.Exit(0)
+			},
+			OnNewLeader: func(identity string) {
+				if identity != id {
+					klog.Infof("new leader elected: %s", identity)
+				}
+			},
+		},
+	})
 }
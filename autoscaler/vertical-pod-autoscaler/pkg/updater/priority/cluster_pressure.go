@@ -0,0 +1,194 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"flag"
+	"fmt"
+
+	apiv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	policylisters "k8s.io/client-go/listers/policy/v1"
+	metricsclientset "k8s.io/metrics/pkg/client/clientset/versioned"
+)
+
+var (
+	scaleDownPriorityNodeCPUThreshold = flag.Float64("scale-down-priority-node-cpu-threshold", 0.85,
+		`Fraction of aggregate node-allocatable CPU in use above which the updater considers the
+		cluster under pressure and prioritizes scale-down pods over scale-up ones.`)
+
+	scaleDownPriorityPendingPods = flag.Int("scale-down-priority-pending-pods", 1,
+		`Number of unschedulable/pending pods above which the updater considers the cluster
+		under pressure and prioritizes scale-down pods over scale-up ones.`)
+
+	scaleDownPriorityPDBHeadroom = flag.Bool("scale-down-priority-pdb-headroom", true,
+		`If true, the updater also treats a cluster where any PodDisruptionBudget has no
+		disruptions allowed as under pressure, and prioritizes scale-down pods over scale-up
+		ones.`)
+)
+
+// ClusterPressureSignal reports whether some aspect of cluster capacity is
+// under enough pressure that the updater should prefer freeing resources
+// (prioritizing scale-down pods) over growing them (prioritizing scale-up
+// pods). reason is a short human-readable explanation, used for logging only.
+// ClusterPressureSignal表示集群容量的某个方面是否承受了足够大的压力，使得
+// updater应当优先释放资源（优先处理scale-down的pod），而不是继续扩大资源占用
+// （优先处理scale-up的pod）。reason是一句简短的、仅用于日志的说明。
+type ClusterPressureSignal interface {
+	Pressure() (underPressure bool, reason string)
+}
+
+// anyUnderPressure reports whether any of signals currently reports pressure,
+// along with that signal's reason. Returns false with no reason if signals is
+// empty or none of them report pressure - the scale-up-first ordering from
+// before ClusterPressureSignal was introduced.
+func anyUnderPressure(signals []ClusterPressureSignal) (bool, string) {
+	for _, signal := range signals {
+		if underPressure, reason := signal.Pressure(); underPressure {
+			return true, reason
+		}
+	}
+	return false, ""
+}
+
+// NodeAllocatableCPUPressureSignal reports pressure when the fraction of
+// aggregate node-allocatable CPU currently requested across the cluster, as
+// observed through the metrics-server API, is at or above threshold.
+type NodeAllocatableCPUPressureSignal struct {
+	nodeLister    corelisters.NodeLister
+	metricsClient metricsclientset.Interface
+	threshold     float64
+}
+
+// NewNodeAllocatableCPUPressureSignal returns a ClusterPressureSignal backed
+// by nodeLister (for allocatable capacity) and metricsClient (for current
+// usage), reporting pressure once usage reaches threshold.
+func NewNodeAllocatableCPUPressureSignal(nodeLister corelisters.NodeLister, metricsClient metricsclientset.Interface, threshold float64) *NodeAllocatableCPUPressureSignal {
+	return &NodeAllocatableCPUPressureSignal{nodeLister: nodeLister, metricsClient: metricsClient, threshold: threshold}
+}
+
+// Pressure implements ClusterPressureSignal.
+func (s *NodeAllocatableCPUPressureSignal) Pressure() (bool, string) {
+	nodes, err := s.nodeLister.List(labels.Everything())
+	if err != nil || len(nodes) == 0 {
+		return false, ""
+	}
+	var allocatable int64
+	for _, node := range nodes {
+		allocatable += node.Status.Allocatable.Cpu().MilliValue()
+	}
+	if allocatable == 0 {
+		return false, ""
+	}
+	nodeMetricsList, err := s.metricsClient.MetricsV1beta1().NodeMetricses().List(metav1.ListOptions{})
+	if err != nil {
+		return false, ""
+	}
+	var used int64
+	for _, nodeMetrics := range nodeMetricsList.Items {
+		used += nodeMetrics.Usage.Cpu().MilliValue()
+	}
+	fraction := float64(used) / float64(allocatable)
+	if fraction >= s.threshold {
+		return true, fmt.Sprintf("node-allocatable CPU usage %.0f%% at or above threshold %.0f%%", fraction*100, s.threshold*100)
+	}
+	return false, ""
+}
+
+// PendingPodCountSignal reports pressure when the number of pods in the
+// Pending phase with no node assigned yet reaches threshold.
+type PendingPodCountSignal struct {
+	podLister corelisters.PodLister
+	threshold int
+}
+
+// NewPendingPodCountSignal returns a ClusterPressureSignal backed by
+// podLister, reporting pressure once the count of unschedulable pods reaches
+// threshold.
+func NewPendingPodCountSignal(podLister corelisters.PodLister, threshold int) *PendingPodCountSignal {
+	return &PendingPodCountSignal{podLister: podLister, threshold: threshold}
+}
+
+// Pressure implements ClusterPressureSignal.
+func (s *PendingPodCountSignal) Pressure() (bool, string) {
+	pods, err := s.podLister.List(labels.Everything())
+	if err != nil {
+		return false, ""
+	}
+	pending := 0
+	for _, pod := range pods {
+		if pod.Status.Phase == apiv1.PodPending && pod.Spec.NodeName == "" {
+			pending++
+		}
+	}
+	if pending >= s.threshold {
+		return true, fmt.Sprintf("%d pending pods at or above threshold %d", pending, s.threshold)
+	}
+	return false, ""
+}
+
+// PodDisruptionBudgetHeadroomSignal reports pressure when any
+// PodDisruptionBudget in the cluster has no disruption headroom left, i.e.
+// evicting one more pod covered by it would violate its minimum availability.
+// A cluster in that state is in no position to also absorb scale-up churn.
+type PodDisruptionBudgetHeadroomSignal struct {
+	pdbLister policylisters.PodDisruptionBudgetLister
+}
+
+// NewPodDisruptionBudgetHeadroomSignal returns a ClusterPressureSignal backed
+// by pdbLister.
+func NewPodDisruptionBudgetHeadroomSignal(pdbLister policylisters.PodDisruptionBudgetLister) *PodDisruptionBudgetHeadroomSignal {
+	return &PodDisruptionBudgetHeadroomSignal{pdbLister: pdbLister}
+}
+
+// Pressure implements ClusterPressureSignal.
+func (s *PodDisruptionBudgetHeadroomSignal) Pressure() (bool, string) {
+	pdbs, err := s.pdbLister.List(labels.Everything())
+	if err != nil {
+		return false, ""
+	}
+	for _, pdb := range pdbs {
+		if pdb.Status.DisruptionsAllowed <= 0 {
+			return true, fmt.Sprintf("PodDisruptionBudget %s/%s has no disruptions allowed", pdb.Namespace, pdb.Name)
+		}
+	}
+	return false, ""
+}
+
+// NewDefaultClusterPressureSignals returns the set of ClusterPressureSignal
+// the updater wires into its UpdatePriorityCalculator by default, reading
+// --scale-down-priority-node-cpu-threshold, --scale-down-priority-pending-pods
+// and --scale-down-priority-pdb-headroom for their configuration. The
+// PodDisruptionBudgetHeadroomSignal is omitted entirely when
+// --scale-down-priority-pdb-headroom is false.
+// NewDefaultClusterPressureSignals返回updater默认接入其UpdatePriorityCalculator
+// 的ClusterPressureSignal集合，它们的配置分别读取自
+// --scale-down-priority-node-cpu-threshold、--scale-down-priority-pending-pods
+// 和--scale-down-priority-pdb-headroom。当--scale-down-priority-pdb-headroom为
+// false时，PodDisruptionBudgetHeadroomSignal会被完全省略。
+func NewDefaultClusterPressureSignals(nodeLister corelisters.NodeLister, podLister corelisters.PodLister, pdbLister policylisters.PodDisruptionBudgetLister, metricsClient metricsclientset.Interface) []ClusterPressureSignal {
+	signals := []ClusterPressureSignal{
+		NewNodeAllocatableCPUPressureSignal(nodeLister, metricsClient, *scaleDownPriorityNodeCPUThreshold),
+		NewPendingPodCountSignal(podLister, *scaleDownPriorityPendingPods),
+	}
+	if *scaleDownPriorityPDBHeadroom {
+		signals = append(signals, NewPodDisruptionBudgetHeadroomSignal(pdbLister))
+	}
+	return signals
+}
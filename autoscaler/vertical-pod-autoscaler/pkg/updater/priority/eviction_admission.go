@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"flag"
+
+	apiv1 "k8s.io/api/core/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	"k8s.io/klog"
+)
+
+// PodEvictionAdmission controls whether a pod update that GetSortedPods
+// would otherwise return is actually allowed to go ahead. Implementations
+// are consulted once per pod, in priority order, and can veto individual
+// pods (e.g. because evicting them would be unsafe) without affecting the
+// ordering of the rest.
+// PodEvictionAdmission控制一个本来会被GetSortedPods返回的pod更新是否真的被
+// 允许执行。实现会按优先级顺序对每个pod分别征询一次，可以否决单个pod（比如因为
+// 驱逐它是不安全的），而不影响其余pod的排序。
+type PodEvictionAdmission interface {
+	// Admit returns whether pod may be updated with recommendation.
+	Admit(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) bool
+}
+
+var (
+	respectPodPriority = flag.Bool("respect-pod-priority", false,
+		`If true, QoSPriorityAdmission also refuses to evict pods whose spec.priority is
+		below the value of --min-priority-class-to-evict.`)
+
+	minPriorityClassToEvict = flag.Int("min-priority-class-to-evict", 0,
+		`Only used when --respect-pod-priority is set. Pods with spec.priority below this
+		value are never evicted.`)
+
+	criticalPriorityClasses = map[string]bool{
+		"system-cluster-critical": true,
+		"system-node-critical":    true,
+	}
+)
+
+// QoSPriorityAdmission is the PodEvictionAdmission VPA applies by default. It
+// refuses to evict pods in a critical priority class
+// (system-cluster-critical/system-node-critical) outright, and - when
+// --respect-pod-priority is set - also refuses pods whose spec.priority
+// falls below --min-priority-class-to-evict.
+// QoSPriorityAdmission是VPA默认使用的PodEvictionAdmission。它会直接拒绝驱逐
+// 属于critical优先级类（system-cluster-critical/system-node-critical）的pod；
+// 当设置了--respect-pod-priority时，还会拒绝spec.priority低于
+// --min-priority-class-to-evict的pod。
+type QoSPriorityAdmission struct {
+	respectPodPriority      bool
+	minPriorityClassToEvict int32
+}
+
+// NewQoSPriorityAdmission creates a QoSPriorityAdmission reading its
+// configuration from --respect-pod-priority and --min-priority-class-to-evict.
+func NewQoSPriorityAdmission() *QoSPriorityAdmission {
+	return &QoSPriorityAdmission{
+		respectPodPriority:      *respectPodPriority,
+		minPriorityClassToEvict: int32(*minPriorityClassToEvict),
+	}
+}
+
+// Admit implements PodEvictionAdmission.
+func (a *QoSPriorityAdmission) Admit(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) bool {
+	if criticalPriorityClasses[pod.Spec.PriorityClassName] {
+		klog.V(2).Infof("refusing to evict %s/%s: priority class %q is critical", pod.Namespace, pod.Name, pod.Spec.PriorityClassName)
+		return false
+	}
+	if a.respectPodPriority && pod.Spec.Priority != nil && *pod.Spec.Priority < a.minPriorityClassToEvict {
+		klog.V(2).Infof("refusing to evict %s/%s: priority %d is below --min-priority-class-to-evict (%d)", pod.Namespace, pod.Name, *pod.Spec.Priority, a.minPriorityClassToEvict)
+		return false
+	}
+	return true
+}
+
+// podQOS returns pod's QoS class. It trusts pod.Status.QOSClass when the
+// kubelet has already set it, and otherwise derives it from pod.Spec the
+// same way the kubelet does: Guaranteed if every container has equal,
+// non-zero requests and limits for cpu and memory; BestEffort if no
+// container specifies any of them; Burstable otherwise.
+// podQOS返回pod的QoS类别。如果kubelet已经设置了pod.Status.QOSClass，就直接信任
+// 它；否则按照kubelet同样的算法从pod.Spec推导：如果每个容器对cpu和memory都有
+// 相等且非零的requests和limits，则为Guaranteed；如果没有任何容器指定它们，则为
+// BestEffort；否则为Burstable。
+func podQOS(pod *apiv1.Pod) apiv1.PodQOSClass {
+	if pod.Status.QOSClass != "" {
+		return pod.Status.QOSClass
+	}
+
+	requestsFound := false
+	limitsFound := false
+	guaranteed := true
+	for _, container := range pod.Spec.Containers {
+		for _, resourceName := range []apiv1.ResourceName{apiv1.ResourceCPU, apiv1.ResourceMemory} {
+			request, hasRequest := container.Resources.Requests[resourceName]
+			limit, hasLimit := container.Resources.Limits[resourceName]
+			if hasRequest && !request.IsZero() {
+				requestsFound = true
+			}
+			if hasLimit && !limit.IsZero() {
+				limitsFound = true
+			}
+			if !hasRequest || !hasLimit || request.Cmp(limit) != 0 {
+				guaranteed = false
+			}
+		}
+	}
+	switch {
+	case guaranteed && limitsFound:
+		return apiv1.PodQOSGuaranteed
+	case !requestsFound && !limitsFound:
+		return apiv1.PodQOSBestEffort
+	default:
+		return apiv1.PodQOSBurstable
+	}
+}
@@ -0,0 +1,350 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	vpa_types "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/apis/autoscaling.k8s.io/v1"
+	metrics_updater "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/metrics/updater"
+	vpa_api_util "k8s.io/autoscaler/vertical-pod-autoscaler/pkg/utils/vpa"
+)
+
+// ScoredPriority is the result of running a PriorityScorer over a single
+// pod: the priority byPriority sorts on, plus the bookkeeping AddPod needs
+// to decide whether an update is allowed at all.
+// ScoredPriority是对单个pod运行PriorityScorer的结果：byPriority用来排序的
+// priority，以及AddPod用来判断是否允许更新的一些记录信息。
+type ScoredPriority struct {
+	// Score is this pod's update priority; pods with a higher Score are
+	// evicted first.
+	Score float64
+	// ScaleUp is true if any container wants to grow.
+	ScaleUp bool
+	// OutsideRecommendedRange is true if any container's current request
+	// falls outside of its recommended range.
+	OutsideRecommendedRange bool
+}
+
+// PriorityScorer computes a pod's update priority. byPriority.Less and
+// getUpdatePriority used to hardcode this computation as a fraction-diff
+// comparison; it is now pluggable so operators can pick the eviction
+// ordering that matches how they want to trade off stability vs
+// responsiveness, and can register their own scorers programmatically.
+// PriorityScorer计算一个pod的更新优先级。byPriority.Less和getUpdatePriority
+// 以前把这个计算硬编码成一次fraction-diff比较；现在它是可插拔的，这样运营商可以
+// 选择符合他们在稳定性与响应速度之间权衡取舍的驱逐顺序，也可以通过编程方式注册
+// 自己的scorer。
+type PriorityScorer interface {
+	// Score returns the priority of pod given its (already processed)
+	// recommendation.
+	Score(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, now time.Time) ScoredPriority
+}
+
+// ScorerFactory builds a PriorityScorer. Used by Register/New below.
+type ScorerFactory func() PriorityScorer
+
+var scorerRegistry = map[string]ScorerFactory{
+	"fraction-diff":   func() PriorityScorer { return NewFractionDiffPriorityScorer() },
+	"percentile-drift": func() PriorityScorer { return NewPercentileDriftPriorityScorer() },
+	"oom-weighted": func() PriorityScorer {
+		return NewOOMWeightedPriorityScorer(NewFractionDiffPriorityScorer(), *oomWeightLookback)
+	},
+}
+
+// RegisterScorer adds a named PriorityScorer factory to the registry, so it
+// can be selected via --priority-scorer. Third parties are expected to call
+// this from an init() function in the package implementing the scorer,
+// mirroring the storage backend registry in
+// pkg/recommender/input/storage.
+// RegisterScorer把一个命名的PriorityScorer工厂加入到registry中，这样它就可以
+// 通过--priority-scorer来选择。第三方应当在实现该scorer的包的init()函数中调用
+// 这个方法，这与pkg/recommender/input/storage中的存储后端registry是一致的。
+func RegisterScorer(name string, factory ScorerFactory) {
+	if _, exists := scorerRegistry[name]; exists {
+		panic(fmt.Sprintf("priority scorer %q already registered", name))
+	}
+	scorerRegistry[name] = factory
+}
+
+// NewScorer builds the PriorityScorer registered under name, or an error if
+// name is unknown.
+func NewScorer(name string) (PriorityScorer, error) {
+	factory, found := scorerRegistry[name]
+	if !found {
+		return nil, fmt.Errorf("unknown priority scorer %q, known scorers: %v", name, knownScorers())
+	}
+	return factory(), nil
+}
+
+func knownScorers() []string {
+	names := make([]string, 0, len(scorerRegistry))
+	for name := range scorerRegistry {
+		names = append(names, name)
+	}
+	return names
+}
+
+// resourceDiffStats is the raw bookkeeping shared by all built-in
+// PriorityScorer implementations below: whether some container's request
+// falls outside its recommended range, whether any container wants to
+// scale up, and the relative aggregate difference between requested and
+// recommended resources. Kept separate from the scoring itself so that
+// percentile-drift and OOM-weighted scoring can reuse it without
+// recomputing the same per-container loop.
+func resourceDiffStats(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) (outsideRecommendedRange, scaleUp bool, resourceDiff float64) {
+	totalRequestPerResource := make(map[apiv1.ResourceName]int64)
+	totalRecommendedPerResource := make(map[apiv1.ResourceName]int64)
+
+	for _, podContainer := range pod.Spec.Containers {
+		recommendedRequest := vpa_api_util.GetRecommendationForContainer(podContainer.Name, recommendation)
+		if recommendedRequest == nil {
+			continue
+		}
+		for resourceName, recommended := range recommendedRequest.Target {
+			totalRecommendedPerResource[resourceName] += recommended.MilliValue()
+			lowerBound, hasLowerBound := recommendedRequest.LowerBound[resourceName]
+			upperBound, hasUpperBound := recommendedRequest.UpperBound[resourceName]
+			if request, hasRequest := podContainer.Resources.Requests[resourceName]; hasRequest {
+				totalRequestPerResource[resourceName] += request.MilliValue()
+				if recommended.MilliValue() > request.MilliValue() {
+					scaleUp = true
+				}
+				if (hasLowerBound && request.Cmp(lowerBound) < 0) ||
+					(hasUpperBound && request.Cmp(upperBound) > 0) {
+					outsideRecommendedRange = true
+				}
+			} else {
+				// Note: if the request is not specified, the container will use the
+				// namespace default request. Currently we ignore it and treat such
+				// containers as if they had 0 request. A more correct approach would
+				// be to always calculate the 'effective' request.
+				scaleUp = true
+				outsideRecommendedRange = true
+			}
+		}
+	}
+	for resource, totalRecommended := range totalRecommendedPerResource {
+		totalRequest := math.Max(float64(totalRequestPerResource[resource]), 1.0)
+		resourceDiff += math.Abs(totalRequest-float64(totalRecommended)) / totalRequest
+	}
+	return outsideRecommendedRange, scaleUp, resourceDiff
+}
+
+// ContainerResourceDiff is the relative difference between a single
+// container's current request and its recommended target, broken out per
+// resource, together with whether that diff is small enough for the
+// container to be skipped entirely when applying the update (e.g. via the
+// in-place resize path) - so a pod with several containers, only some of
+// which actually changed, only has those resized.
+// ContainerResourceDiff是单个容器当前request与推荐target之间的相对差异，按
+// 资源拆分，并附带这个差异是否小到足以让该容器在应用更新时被完全跳过（比如在
+// in-place resize路径中）——这样一个有若干容器、只有部分容器真正发生变化的pod，
+// 就只会resize那些真正变化的容器。
+type ContainerResourceDiff struct {
+	ContainerName string
+	CPUDiff       float64
+	MemoryDiff    float64
+	// Skip is true if every resource's diff falls under the calculator's
+	// configured thresholds, so this container can be left untouched.
+	Skip bool
+}
+
+// perContainerResourceDiffs computes a ContainerResourceDiff for every
+// container in pod with a matching recommendation, marking containers whose
+// diff falls under config's thresholds as eligible to be skipped. Also
+// reports the per-container, per-resource diff via metrics_updater so
+// operators can see which containers are actually driving updates.
+func perContainerResourceDiffs(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, config *UpdateConfig) []ContainerResourceDiff {
+	var diffs []ContainerResourceDiff
+	for _, container := range pod.Spec.Containers {
+		recommendedRequest := vpa_api_util.GetRecommendationForContainer(container.Name, recommendation)
+		if recommendedRequest == nil {
+			continue
+		}
+		diff := ContainerResourceDiff{
+			ContainerName: container.Name,
+			CPUDiff:       relativeResourceDiff(container.Resources.Requests, recommendedRequest.Target, apiv1.ResourceCPU),
+			MemoryDiff:    relativeResourceDiff(container.Resources.Requests, recommendedRequest.Target, apiv1.ResourceMemory),
+		}
+		diff.Skip = diff.CPUDiff < config.MinCPUChangePriority &&
+			diff.MemoryDiff < config.MinMemoryChangePriority &&
+			diff.CPUDiff+diff.MemoryDiff < config.MinPerContainerChangePriority
+		metrics_updater.ObserveContainerResourceDiff(pod.Namespace, container.Name, apiv1.ResourceCPU, diff.CPUDiff)
+		metrics_updater.ObserveContainerResourceDiff(pod.Namespace, container.Name, apiv1.ResourceMemory, diff.MemoryDiff)
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// anyContainerNeedsUpdate reports whether at least one of diffs is not
+// marked Skip, i.e. whether the pod needs an update at all.
+func anyContainerNeedsUpdate(diffs []ContainerResourceDiff) bool {
+	for _, diff := range diffs {
+		if !diff.Skip {
+			return true
+		}
+	}
+	return false
+}
+
+func relativeResourceDiff(requests, recommended apiv1.ResourceList, resourceName apiv1.ResourceName) float64 {
+	recommendedValue, hasRecommended := recommended[resourceName]
+	if !hasRecommended {
+		return 0
+	}
+	requestValue := requests[resourceName]
+	request := math.Max(float64(requestValue.MilliValue()), 1.0)
+	return math.Abs(request-float64(recommendedValue.MilliValue())) / request
+}
+
+// FractionDiffPriorityScorer is the scorer VPA has always used: priority is
+// proportional to the fraction by which total requested resources should be
+// increased or decreased, e.g. a pod with 10M current memory and a 20M
+// recommendation scores higher than one with 100M current and 150M
+// recommended (100% increase vs 50% increase). This is the default scorer.
+type FractionDiffPriorityScorer struct{}
+
+// NewFractionDiffPriorityScorer returns the default fraction-diff scorer.
+func NewFractionDiffPriorityScorer() *FractionDiffPriorityScorer {
+	return &FractionDiffPriorityScorer{}
+}
+
+// Score implements PriorityScorer.
+func (s *FractionDiffPriorityScorer) Score(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, now time.Time) ScoredPriority {
+	outsideRecommendedRange, scaleUp, resourceDiff := resourceDiffStats(pod, recommendation)
+	return ScoredPriority{Score: resourceDiff, ScaleUp: scaleUp, OutsideRecommendedRange: outsideRecommendedRange}
+}
+
+// PercentileDriftPriorityScorer prioritizes pods whose current request sits
+// closest to either edge of the [LowerBound, UpperBound] band the
+// recommender already derived from its PercentileEstimator/EstimationPolicy
+// for each container - i.e. the same percentile-of-usage distribution the
+// recommender uses to compute those bounds. Unlike FractionDiffPriorityScorer,
+// which only looks at distance to Target, this one measures how close the
+// request already sits to the edge of the band the recommender considers
+// safe, so pods drifting toward an under- or over-provisioned extreme are
+// evicted first.
+// PercentileDriftPriorityScorer优先处理那些当前请求最靠近recommender已经为每个
+// 容器根据PercentileEstimator/EstimationPolicy算出的[LowerBound, UpperBound]
+// 区间边缘的pod——也就是recommender用来算出这些边界的同一个usage百分位分布。
+// 和只看与Target距离的FractionDiffPriorityScorer不同，这个scorer衡量的是请求
+// 已经有多靠近recommender认为安全的区间边缘，因此漂向under-或over-provisioned
+// 极端的pod会被优先驱逐。
+type PercentileDriftPriorityScorer struct{}
+
+// NewPercentileDriftPriorityScorer returns a percentile-drift scorer.
+func NewPercentileDriftPriorityScorer() *PercentileDriftPriorityScorer {
+	return &PercentileDriftPriorityScorer{}
+}
+
+// Score implements PriorityScorer.
+func (s *PercentileDriftPriorityScorer) Score(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, now time.Time) ScoredPriority {
+	outsideRecommendedRange, scaleUp, _ := resourceDiffStats(pod, recommendation)
+
+	var maxEdgeDistance float64
+	for _, container := range pod.Spec.Containers {
+		rec := vpa_api_util.GetRecommendationForContainer(container.Name, recommendation)
+		if rec == nil {
+			continue
+		}
+		for resourceName, request := range container.Resources.Requests {
+			lowerBound, hasLower := rec.LowerBound[resourceName]
+			upperBound, hasUpper := rec.UpperBound[resourceName]
+			if !hasLower || !hasUpper {
+				continue
+			}
+			span := float64(upperBound.MilliValue() - lowerBound.MilliValue())
+			if span <= 0 {
+				continue
+			}
+			position := (float64(request.MilliValue()) - float64(lowerBound.MilliValue())) / span
+			if position < 0 {
+				position = 0
+			} else if position > 1 {
+				position = 1
+			}
+			// Distance from the middle of the band, rescaled to [0, 1]:
+			// 0 means the request sits right in the middle, 1 means it sits
+			// right on the lower or upper percentile bound.
+			edgeDistance := math.Abs(position-0.5) * 2
+			if edgeDistance > maxEdgeDistance {
+				maxEdgeDistance = edgeDistance
+			}
+		}
+	}
+	return ScoredPriority{Score: maxEdgeDistance, ScaleUp: scaleUp, OutsideRecommendedRange: outsideRecommendedRange}
+}
+
+// OOMWeightedPriorityScorer wraps another PriorityScorer and multiplies its
+// score by a decaying weight based on how recently the pod's containers
+// were OOMKilled, generalizing the single-container "quick OOM" special
+// case in AddPod into a continuous weight that applies to every pod the
+// calculator considers. A container OOMKilled just now doubles the
+// delegate's score; the boost decays linearly to 1 (no boost at all) once
+// the most recent OOM falls outside oomLookback.
+// OOMWeightedPriorityScorer包装另一个PriorityScorer，并根据pod的容器最近一次
+// 被OOMKilled的时间，把它的score乘上一个衰减的权重，这把AddPod中针对单容器的
+// "quick OOM"特殊处理，泛化成了一个适用于calculator考虑的每一个pod的连续权重。
+// 刚刚发生OOMKilled的容器会使delegate的score翻倍；一旦最近一次OOM超出了
+// oomLookback窗口，这个加成就会线性衰减到1（完全没有加成）。
+type OOMWeightedPriorityScorer struct {
+	delegate    PriorityScorer
+	oomLookback time.Duration
+}
+
+// NewOOMWeightedPriorityScorer returns a scorer that weights delegate's score
+// by recent OOMKilled activity within oomLookback. If delegate is nil, the
+// default FractionDiffPriorityScorer is used.
+func NewOOMWeightedPriorityScorer(delegate PriorityScorer, oomLookback time.Duration) *OOMWeightedPriorityScorer {
+	if delegate == nil {
+		delegate = NewFractionDiffPriorityScorer()
+	}
+	return &OOMWeightedPriorityScorer{delegate: delegate, oomLookback: oomLookback}
+}
+
+// Score implements PriorityScorer.
+func (s *OOMWeightedPriorityScorer) Score(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, now time.Time) ScoredPriority {
+	scored := s.delegate.Score(pod, recommendation, now)
+	scored.Score *= s.oomWeight(pod, now)
+	return scored
+}
+
+func (s *OOMWeightedPriorityScorer) oomWeight(pod *apiv1.Pod, now time.Time) float64 {
+	weight := 1.0
+	if s.oomLookback <= 0 {
+		return weight
+	}
+	for _, containerStatus := range pod.Status.ContainerStatuses {
+		terminated := containerStatus.LastTerminationState.Terminated
+		if terminated == nil || terminated.Reason != "OOMKilled" {
+			continue
+		}
+		age := now.Sub(terminated.FinishedAt.Time)
+		if age < 0 || age > s.oomLookback {
+			continue
+		}
+		containerWeight := 1.0 + (1.0 - float64(age)/float64(s.oomLookback))
+		if containerWeight > weight {
+			weight = containerWeight
+		}
+	}
+	return weight
+}
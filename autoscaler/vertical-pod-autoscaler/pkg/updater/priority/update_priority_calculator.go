@@ -18,7 +18,6 @@ package priority
 
 import (
 	"flag"
-	"math"
 	"sort"
 	"time"
 
@@ -40,40 +39,120 @@ var (
 	evictAfterOOMThreshold = flag.Duration("evict-after-oom-threshold", 10*time.Minute,
 		`Evict pod that has only one container and it OOMed in less than
 		evict-after-oom-threshold since start.`)
+
+	priorityScorerName = flag.String("priority-scorer", "fraction-diff",
+		`Which PriorityScorer to use to order pods for eviction: fraction-diff (default),
+		percentile-drift or oom-weighted. See pkg/updater/priority/priority_scorer.go.`)
+
+	oomWeightLookback = flag.Duration("priority-scorer-oom-lookback", time.Hour,
+		`How far back the oom-weighted priority scorer looks for OOMKilled containers
+		when computing its decaying weight.`)
+)
+
+// InPlaceUpdateMode controls whether the updater may route an accepted
+// update through the Kubernetes 1.27+ pods/resize subresource instead of
+// evict-and-recreate.
+// InPlaceUpdateMode控制updater是否可以把一次被接受的更新，通过Kubernetes 1.27+
+// 的pods/resize子资源来完成，而不是驱逐重建。
+type InPlaceUpdateMode string
+
+const (
+	// InPlaceUpdateModeDisabled never routes updates through the resize
+	// subresource; every accepted update is evicted, same as before this was
+	// introduced.
+	InPlaceUpdateModeDisabled InPlaceUpdateMode = "Disabled"
+	// InPlaceUpdateModePreferInPlace routes a pod through the resize
+	// subresource when its recommendation fits within every changed
+	// container's resizePolicy, falling back to eviction otherwise.
+	InPlaceUpdateModePreferInPlace InPlaceUpdateMode = "PreferInPlace"
+	// InPlaceUpdateModeInPlaceOnly only ever resizes in place; pods whose
+	// recommendation does not fit within their resizePolicy are left alone
+	// rather than evicted.
+	InPlaceUpdateModeInPlaceOnly InPlaceUpdateMode = "InPlaceOnly"
 )
 
 // UpdatePriorityCalculator is responsible for prioritizing updates on pods.
 // It can returns a sorted list of pods in order of update priority.
-// Update priority is proportional to fraction by which resources should be increased / decreased.
-// i.e. pod with 10M current memory and recommendation 20M will have higher update priority
-// than pod with 100M current memory and 150M recommendation (100% increase vs 50% increase)
+// Update priority is computed by a pluggable PriorityScorer; by default
+// (the fraction-diff scorer) it is proportional to fraction by which
+// resources should be increased / decreased, i.e. pod with 10M current
+// memory and recommendation 20M will have higher update priority than pod
+// with 100M current memory and 150M recommendation (100% increase vs 50%
+// increase). See NewScorer/RegisterScorer in priority_scorer.go for the
+// other built-in scorers and how to plug in a custom one.
 type UpdatePriorityCalculator struct {
 	resourcesPolicy         *vpa_types.PodResourcePolicy
 	conditions              []vpa_types.VerticalPodAutoscalerCondition
 	pods                    []podPriority
 	config                  *UpdateConfig
 	recommendationProcessor vpa_api_util.RecommendationProcessor
+	scorer                  PriorityScorer
+	inPlaceUpdateMode       InPlaceUpdateMode
+	pressureSignals         []ClusterPressureSignal
 }
 
 // UpdateConfig holds configuration for UpdatePriorityCalculator
 type UpdateConfig struct {
-	// MinChangePriority is the minimum change priority that will trigger a update.
-	// TODO: should have separate for Mem and CPU?
-	MinChangePriority float64
+	// MinCPUChangePriority is the minimum relative CPU diff for a single
+	// container that will trigger an update of that container.
+	MinCPUChangePriority float64
+	// MinMemoryChangePriority is the minimum relative memory diff for a
+	// single container that will trigger an update of that container.
+	MinMemoryChangePriority float64
+	// MinPerContainerChangePriority is the minimum combined (CPU+memory)
+	// relative diff for a single container that will trigger an update of
+	// that container, even when neither MinCPUChangePriority nor
+	// MinMemoryChangePriority is met on its own.
+	MinPerContainerChangePriority float64
 }
 
 // NewUpdatePriorityCalculator creates new UpdatePriorityCalculator for the given resources policy and configuration.
 // If the given policy is nil, there will be no policy restriction on update.
 // If the given config is nil, default values are used.
-// 更新优先级计算
+// If scorer is nil, the scorer named by --priority-scorer is looked up in the
+// registry (falling back to the fraction-diff scorer on an unknown name), so
+// that callers wanting a custom PriorityScorer can pass it here directly
+// instead of going through RegisterScorer/--priority-scorer.
+// If inPlaceUpdateMode is empty, InPlaceUpdateModeDisabled is used.
+// pressureSignals are consulted on every AddPod call to decide whether the
+// cluster is currently short on capacity; if any of them reports pressure,
+// byPriority sorts scale-down pods ahead of scale-up ones for that pod,
+// instead of the usual scale-up-first ordering, so the updater frees up
+// capacity for pending workloads rather than growing requests further.
+// 更新优先级计算。如果scorer为nil，则会在registry中查找--priority-scorer指定
+// 的scorer（如果名字未知则回退到fraction-diff scorer），因此想要使用自定义
+// PriorityScorer的调用者可以直接在这里传入，而不必通过RegisterScorer和
+// --priority-scorer。如果inPlaceUpdateMode为空，则使用InPlaceUpdateModeDisabled。
+// pressureSignals会在每次调用AddPod时被查询，用来判断集群当前是否容量紧张；
+// 只要其中任意一个报告了压力，byPriority在给这个pod排序时就会让scale-down的pod
+// 排在scale-up的pod前面，而不是平常scale-up优先的顺序，这样updater就能为待调度
+// 的workload腾出容量，而不是继续扩大资源请求。
 func NewUpdatePriorityCalculator(policy *vpa_types.PodResourcePolicy,
 	conditions []vpa_types.VerticalPodAutoscalerCondition,
 	config *UpdateConfig,
-	processor vpa_api_util.RecommendationProcessor) UpdatePriorityCalculator {
+	processor vpa_api_util.RecommendationProcessor,
+	scorer PriorityScorer,
+	inPlaceUpdateMode InPlaceUpdateMode,
+	pressureSignals ...ClusterPressureSignal) UpdatePriorityCalculator {
 	if config == nil {
-		config = &UpdateConfig{MinChangePriority: defaultUpdateThreshold}
+		config = &UpdateConfig{
+			MinCPUChangePriority:          defaultUpdateThreshold,
+			MinMemoryChangePriority:       defaultUpdateThreshold,
+			MinPerContainerChangePriority: defaultUpdateThreshold,
+		}
+	}
+	if scorer == nil {
+		var err error
+		scorer, err = NewScorer(*priorityScorerName)
+		if err != nil {
+			klog.Errorf("falling back to fraction-diff priority scorer: %v", err)
+			scorer = NewFractionDiffPriorityScorer()
+		}
+	}
+	if inPlaceUpdateMode == "" {
+		inPlaceUpdateMode = InPlaceUpdateModeDisabled
 	}
-	return UpdatePriorityCalculator{resourcesPolicy: policy, conditions: conditions, config: config, recommendationProcessor: processor}
+	return UpdatePriorityCalculator{resourcesPolicy: policy, conditions: conditions, config: config, recommendationProcessor: processor, scorer: scorer, inPlaceUpdateMode: inPlaceUpdateMode, pressureSignals: pressureSignals}
 }
 
 // AddPod adds pod to the UpdatePriorityCalculator.
@@ -86,7 +165,7 @@ func (calc *UpdatePriorityCalculator) AddPod(pod *apiv1.Pod, recommendation *vpa
 		return
 	}
 
-	updatePriority := calc.getUpdatePriority(pod, processedRecommendation)
+	updatePriority := calc.getUpdatePriority(pod, processedRecommendation, now)
 	// 根据建议 pod获取更新优先级
 
 	quickOOM := false
@@ -120,22 +199,32 @@ func (calc *UpdatePriorityCalculator) AddPod(pod *apiv1.Pod, recommendation *vpa
 			klog.V(2).Infof("not updating a short-lived pod %v, request within recommended range", pod.Name)
 			return
 		}
-		if updatePriority.resourceDiff < calc.config.MinChangePriority {
-			klog.V(2).Infof("not updating pod %v, resource diff too low: %v", pod.Name, updatePriority)
+		if !anyContainerNeedsUpdate(updatePriority.containerDiffs) {
+			klog.V(2).Infof("not updating pod %v, every container's diff is below its configured threshold: %v", pod.Name, updatePriority.containerDiffs)
 			return
 		}
 	}
-	klog.V(2).Infof("pod accepted for update %v with priority %v", pod.Name, updatePriority.resourceDiff)
+	updatePriority.action = calc.resolveUpdateAction(pod, processedRecommendation)
+	if updatePriority.action == actionInPlace {
+		klog.V(2).Infof("pod accepted for in-place update %v with priority %v", pod.Name, updatePriority.priority)
+	} else {
+		klog.V(2).Infof("pod accepted for update %v with priority %v", pod.Name, updatePriority.priority)
+	}
 	calc.pods = append(calc.pods, updatePriority)
 	// 进行添加操作
 }
 
-// GetSortedPods returns a list of pods ordered by update priority (highest update priority first)
+// GetSortedPods returns a list of pods to evict, ordered by update priority
+// (highest update priority first). Pods whose update was routed to the
+// in-place resize path are excluded - see GetInPlaceUpdatablePods.
 func (calc *UpdatePriorityCalculator) GetSortedPods(admission PodEvictionAdmission) []*apiv1.Pod {
 	sort.Sort(byPriority(calc.pods))
 	// 进行排序
 	result := []*apiv1.Pod{}
 	for _, podPrio := range calc.pods {
+		if podPrio.action == actionInPlace {
+			continue
+		}
 		if admission == nil || admission.Admit(podPrio.pod, podPrio.recommendation) {
 			// 如果admission能够使得pod接纳该建议，则将结果添加
 			result = append(result, podPrio.pod)
@@ -147,71 +236,141 @@ func (calc *UpdatePriorityCalculator) GetSortedPods(admission PodEvictionAdmissi
 	return result
 }
 
-func (calc *UpdatePriorityCalculator) getUpdatePriority(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) podPriority {
-	outsideRecommendedRange := false
-	scaleUp := false
-	// Sum of requests over all containers, per resource type.
-	totalRequestPerResource := make(map[apiv1.ResourceName]int64)
-	// Sum of recommendations over all containers, per resource type.
-	totalRecommendedPerResource := make(map[apiv1.ResourceName]int64)
-
-	for _, podContainer := range pod.Spec.Containers {
-		recommendedRequest := vpa_api_util.GetRecommendationForContainer(podContainer.Name, recommendation)
-		// 获取推荐资源
+// GetInPlaceUpdatablePods returns the pods whose update was routed to the
+// pods/resize subresource path instead of eviction. Unlike GetSortedPods,
+// these are not subject to PodEvictionAdmission, evictionRateLimit or
+// evictionToleranceFraction - none of that exists to protect against
+// disruption caused by an eviction, and an in-place resize causes none.
+// GetInPlaceUpdatablePods返回那些更新被导向pods/resize子资源路径、而不是驱逐的
+// pod。和GetSortedPods不同，这些pod不受PodEvictionAdmission、evictionRateLimit
+// 或evictionToleranceFraction的限制——这些存在的目的都是为了防范驱逐带来的中断，
+// 而原地resize不会造成这种中断。
+func (calc *UpdatePriorityCalculator) GetInPlaceUpdatablePods() []*apiv1.Pod {
+	result := []*apiv1.Pod{}
+	for _, podPrio := range calc.pods {
+		if podPrio.action == actionInPlace {
+			result = append(result, podPrio.pod)
+		}
+	}
+	return result
+}
+
+// resolveUpdateAction decides whether pod's update should be routed through
+// the in-place resize path, based on the configured InPlaceUpdateMode and
+// whether recommendation fits within every changed container's resizePolicy.
+func (calc *UpdatePriorityCalculator) resolveUpdateAction(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) updateAction {
+	if calc.inPlaceUpdateMode == InPlaceUpdateModeDisabled {
+		return actionEvict
+	}
+	if canResizeInPlace(pod, recommendation) {
+		return actionInPlace
+	}
+	if calc.inPlaceUpdateMode == InPlaceUpdateModeInPlaceOnly {
+		klog.V(2).Infof("pod %v needs a restart to apply its recommendation but in-place-update-mode is InPlaceOnly, leaving it alone", pod.Name)
+	}
+	return actionEvict
+}
+
+// canResizeInPlace reports whether applying recommendation to pod can be
+// done entirely through the pods/resize subresource, i.e. no container whose
+// request would change declares a resizePolicy of RestartContainer for that
+// resource. Containers with no resizePolicy entry for a changed resource
+// default to NotRequired, same as the kubelet.
+// canResizeInPlace表示把recommendation应用到pod上是否可以完全通过pods/resize
+// 子资源完成，即没有任何请求会发生变化的容器为该资源声明了RestartContainer的
+// resizePolicy。对于变化的资源，如果容器没有相应的resizePolicy条目，则默认为
+// NotRequired，和kubelet的行为一致。
+func canResizeInPlace(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources) bool {
+	for _, container := range pod.Spec.Containers {
+		recommendedRequest := vpa_api_util.GetRecommendationForContainer(container.Name, recommendation)
 		if recommendedRequest == nil {
 			continue
 		}
 		for resourceName, recommended := range recommendedRequest.Target {
-			totalRecommendedPerResource[resourceName] += recommended.MilliValue()
-			lowerBound, hasLowerBound := recommendedRequest.LowerBound[resourceName]
-			// 下限
-			upperBound, hasUpperBound := recommendedRequest.UpperBound[resourceName]
-			// 上限
-			if request, hasRequest := podContainer.Resources.Requests[resourceName]; hasRequest {
-				totalRequestPerResource[resourceName] += request.MilliValue()
-				if recommended.MilliValue() > request.MilliValue() {
-					scaleUp = true
-				}
-				if (hasLowerBound && request.Cmp(lowerBound) < 0) ||
-					(hasUpperBound && request.Cmp(upperBound) > 0) {
-					outsideRecommendedRange = true
-				}
-			} else {
-				// Note: if the request is not specified, the container will use the
-				// namespace default request. Currently we ignore it and treat such
-				// containers as if they had 0 request. A more correct approach would
-				// be to always calculate the 'effective' request.
-				scaleUp = true
-				outsideRecommendedRange = true
+			current, hasRequest := container.Resources.Requests[resourceName]
+			if hasRequest && current.Cmp(recommended) == 0 {
+				continue
+			}
+			if requiresRestart(container.ResizePolicy, resourceName) {
+				return false
 			}
 		}
 	}
-	resourceDiff := 0.0
-	for resource, totalRecommended := range totalRecommendedPerResource {
-		totalRequest := math.Max(float64(totalRequestPerResource[resource]), 1.0)
-		// 全部的资源数
-		resourceDiff += math.Abs(totalRequest-float64(totalRecommended)) / totalRequest
-		// 求资源率
+	return true
+}
+
+func requiresRestart(policies []apiv1.ContainerResizePolicy, resourceName apiv1.ResourceName) bool {
+	for _, policy := range policies {
+		if policy.ResourceName == resourceName {
+			return policy.RestartPolicy == apiv1.RestartContainer
+		}
+	}
+	return false
+}
+
+// getUpdatePriority scores pod via the calculator's configured PriorityScorer
+// and bundles the result up together with the pod and its recommendation so
+// GetSortedPods/byPriority don't need to re-derive anything.
+// getUpdatePriority通过calculator配置的PriorityScorer给pod打分，并把结果和pod
+// 及其recommendation一起打包，这样GetSortedPods/byPriority就不需要重新计算了。
+func (calc *UpdatePriorityCalculator) getUpdatePriority(pod *apiv1.Pod, recommendation *vpa_types.RecommendedPodResources, now time.Time) podPriority {
+	scored := calc.scorer.Score(pod, recommendation, now)
+	underPressure, pressureReason := anyUnderPressure(calc.pressureSignals)
+	if underPressure {
+		klog.V(4).Infof("cluster under pressure, prioritizing scale-down pods: %v", pressureReason)
 	}
 	return podPriority{
 		pod:                     pod,
-		outsideRecommendedRange: outsideRecommendedRange, // 外部建议范围
-		scaleUp:                 scaleUp, // 是否扩容
-		resourceDiff:            resourceDiff, // 资源率
-		recommendation:          recommendation, // 建议
+		outsideRecommendedRange: scored.OutsideRecommendedRange,
+		scaleUp:                 scored.ScaleUp,
+		priority:                scored.Score,
+		recommendation:          recommendation,
+		qosClass:                podQOS(pod),
+		containerDiffs:          perContainerResourceDiffs(pod, recommendation, calc.config),
+		scaleDownPrioritized:    underPressure,
+		pressureReason:          pressureReason,
 	}
 }
 
+// updateAction is how an accepted pod update should be carried out.
+type updateAction int
+
+const (
+	actionEvict updateAction = iota
+	actionInPlace
+)
+
 type podPriority struct {
 	pod *apiv1.Pod
 	// Is any container outside of the recommended range.
 	outsideRecommendedRange bool
 	// Does any container want to grow.
 	scaleUp bool
-	// Relative difference between the total requested and total recommended resources.
-	resourceDiff float64
+	// This pod's priority score, as computed by the calculator's PriorityScorer.
+	priority float64
 	// Recommendation for pod
 	recommendation *vpa_types.RecommendedPodResources
+	// How this update should be carried out: eviction or in-place resize.
+	action updateAction
+	// This pod's QoS class, so PodEvictionAdmission implementations (and
+	// byPriority.Less) can take it into account. See podQOS in
+	// eviction_admission.go.
+	qosClass apiv1.PodQOSClass
+	// Per-container, per-resource diffs against recommendation, and whether
+	// each container's diff is small enough to be skipped entirely when
+	// applying the update. See perContainerResourceDiffs.
+	containerDiffs []ContainerResourceDiff
+	// scaleDownPrioritized records whether the calculator's
+	// ClusterPressureSignals reported pressure at the time this pod was
+	// scored, so byPriority.Less sorts scale-down pods ahead of scale-up
+	// ones for it instead of the other way around. Stored on the pod rather
+	// than recomputed in Less so the decision driving the sort order is
+	// visible in logs and reproducible.
+	scaleDownPrioritized bool
+	// pressureReason is the reason string from whichever ClusterPressureSignal
+	// triggered scaleDownPrioritized, for logging. Empty when
+	// scaleDownPrioritized is false.
+	pressureReason string
 }
 
 type byPriority []podPriority
@@ -225,14 +384,26 @@ func (list byPriority) Swap(i, j int) {
 
 // Less implements reverse ordering by priority (highest priority first).
 func (list byPriority) Less(i, j int) bool {
-	// 1. If any container wants to grow, the pod takes precedence.
-	// TODO: A better policy would be to prioritize scaling down when
-	// (a) the pod is pending
-	// (b) there is general resource shortage
-	// and prioritize scaling up otherwise.
+	// 1. Normally a pod that wants to scale up takes precedence, so it gets
+	// its extra resources sooner. But if the calculator's
+	// ClusterPressureSignals reported pressure when this pod was scored -
+	// pods pending scheduling, node-allocatable CPU exhausted, or PDB
+	// headroom gone - the goal flips: the cluster needs capacity back, so a
+	// scale-down pod takes precedence instead, freeing resources for pending
+	// workloads rather than growing requests further.
 	if list[i].scaleUp != list[j].scaleUp {
+		if list[i].scaleDownPrioritized {
+			return !list[i].scaleUp
+		}
 		return list[i].scaleUp
 	}
-	// 2. A pod with larger value of resourceDiff takes precedence.
-	return list[i].resourceDiff > list[j].resourceDiff
+	// 2. A pod with a higher score, as produced by the configured
+	// PriorityScorer, takes precedence.
+	if list[i].priority != list[j].priority {
+		return list[i].priority > list[j].priority
+	}
+	// 3. Within the same score, a Guaranteed pod is evicted last - breaking
+	// the scheduler's resource guarantee on it is more disruptive than doing
+	// the same to an equally-scored Burstable/BestEffort pod.
+	return list[j].qosClass == apiv1.PodQOSGuaranteed && list[i].qosClass != apiv1.PodQOSGuaranteed
 }
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package limitrange
+
+import (
+	"sync"
+	"time"
+
+	core "k8s.io/api/core/v1"
+)
+
+// cachedBounds is one namespace's memoized result, alongside the time it was
+// last refreshed. A nil Bounds is a legitimate cached value (the namespace
+// has no LimitRanges/ResourceQuotas), which is what lets the cache avoid
+// repeating list+merge work for the common no-limits case.
+// cachedBounds是单个命名空间被缓存的结果，以及它最近一次被刷新的时间。
+// nil的Bounds也是一个合法的缓存值（该命名空间没有LimitRanges/ResourceQuotas），
+// 这正是让缓存能够避免在常见的"无限制"场景下重复list+merge的关键。
+type cachedBounds struct {
+	value       *Bounds
+	err         error
+	lastRefresh time.Time
+}
+
+// CachedLimitRangeCalculator wraps any LimitRangeCalculator and memoizes its
+// per-namespace results for a configurable TTL, refreshing lazily on access
+// after expiry - analogous to a cached-counter pattern where the last value
+// is returned immediately and a refresh is only triggered once
+// time.Since(lastRefresh) exceeds the expiration.
+// CachedLimitRangeCalculator包装任意LimitRangeCalculator，并以可配置的TTL
+// 缓存每个命名空间的结果，在过期后惰性刷新——类似于一种缓存计数器模式：
+// 立即返回上一次的值，只有当time.Since(lastRefresh)超过expiration时才触发刷新。
+type CachedLimitRangeCalculator struct {
+	inner      LimitRangeCalculator
+	expiration time.Duration
+
+	mutex sync.RWMutex
+	cache map[string]*cachedBounds
+}
+
+// NewCachedLimitsRangeCalculator returns a CachedLimitRangeCalculator wrapping inner.
+// NewCachedLimitsRangeCalculator返回一个包装inner的CachedLimitRangeCalculator。
+func NewCachedLimitsRangeCalculator(inner LimitRangeCalculator, ttl time.Duration) *CachedLimitRangeCalculator {
+	return &CachedLimitRangeCalculator{
+		inner:      inner,
+		expiration: ttl,
+		cache:      make(map[string]*cachedBounds),
+	}
+}
+
+// GetContainerLimitRangeItem returns the Container item out of the cached Bounds for namespace.
+func (c *CachedLimitRangeCalculator) GetContainerLimitRangeItem(namespace string) (*core.LimitRangeItem, error) {
+	bounds, err := c.GetNamespaceResourceBounds(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return bounds.Container, nil
+}
+
+// GetPodLimitRangeItem returns the Pod item out of the cached Bounds for namespace.
+func (c *CachedLimitRangeCalculator) GetPodLimitRangeItem(namespace string) (*core.LimitRangeItem, error) {
+	bounds, err := c.GetNamespaceResourceBounds(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return bounds.Pod, nil
+}
+
+// GetNamespaceResourceBounds returns the last cached Bounds for namespace,
+// refreshing from the inner calculator if there is no entry yet or the
+// cached entry is older than the configured TTL.
+// GetNamespaceResourceBounds返回该命名空间最近被缓存的Bounds，如果还没有缓存项
+// 或者缓存项的年龄超过了配置的TTL，则从inner calculator刷新。
+func (c *CachedLimitRangeCalculator) GetNamespaceResourceBounds(namespace string) (*Bounds, error) {
+	c.mutex.RLock()
+	entry, found := c.cache[namespace]
+	c.mutex.RUnlock()
+
+	if found && time.Since(entry.lastRefresh) <= c.expiration {
+		return entry.value, entry.err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Re-check under the write lock in case another goroutine refreshed this
+	// namespace while we were waiting for the lock.
+	if entry, found := c.cache[namespace]; found && time.Since(entry.lastRefresh) <= c.expiration {
+		return entry.value, entry.err
+	}
+
+	bounds, err := c.inner.GetNamespaceResourceBounds(namespace)
+	c.cache[namespace] = &cachedBounds{value: bounds, err: err, lastRefresh: time.Now()}
+	return bounds, err
+}
+
+// Purge invalidates the cached entry for namespace, forcing the next access
+// to refresh from the inner calculator regardless of TTL. Intended to be
+// called by the updater/admission-controller on LimitRange/ResourceQuota
+// change events received from the informer.
+// Purge使namespace对应的缓存项失效，使得下一次访问无论TTL是否过期都会从inner
+// calculator重新刷新。预期由updater/admission-controller在从informer收到
+// LimitRange/ResourceQuota变更事件时调用。
+func (c *CachedLimitRangeCalculator) Purge(namespace string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.cache, namespace)
+}
@@ -35,6 +35,34 @@ type LimitRangeCalculator interface {
 	// GetPodLimitRangeItem returns LimitRangeItem that describes limitation on pod limits in the given namespace.
 	// GetPodLimitRangeItem返回LimitRangeItem，它描述给定名称空间中对pod的限制。
 	GetPodLimitRangeItem(namespace string) (*core.LimitRangeItem, error)
+	// GetNamespaceResourceBounds returns the container and pod caps derived from
+	// merging LimitRange items with any headroom left by the namespace's
+	// ResourceQuota objects, so a recommendation never exceeds what admission
+	// will allow.
+	// GetNamespaceResourceBounds返回将LimitRange items与该命名空间ResourceQuota对象
+	// 剩余的headroom合并后得到的container和pod上限，从而使推荐值不会超过admission允许的范围。
+	GetNamespaceResourceBounds(namespace string) (*Bounds, error)
+}
+
+// Bounds bundles the container-level and pod-level LimitRangeItem together
+// with the headroom derived from ResourceQuota, the three inputs that jointly
+// constrain what a VPA recommendation is allowed to request.
+// Bounds将container级和pod级的LimitRangeItem与ResourceQuota推导出的headroom
+// 捆绑在一起，这三者共同约束了VPA推荐值允许请求的范围。
+type Bounds struct {
+	Container *core.LimitRangeItem
+	Pod       *core.LimitRangeItem
+	Quota     *core.LimitRangeItem
+}
+
+// quotaScopedResources lists the ResourceQuota resource names consulted when
+// deriving headroom; the per-scope variants (BestEffort, NotTerminating,
+// PriorityClass) reuse the same underlying resource names.
+var quotaScopedResources = []core.ResourceName{
+	core.ResourceLimitsCPU,
+	core.ResourceLimitsMemory,
+	core.ResourceRequestsCPU,
+	core.ResourceRequestsMemory,
 }
 
 type noopLimitsRangeCalculator struct{}
@@ -47,8 +75,13 @@ func (lc *noopLimitsRangeCalculator) GetPodLimitRangeItem(namespace string) (*co
 	return nil, nil
 }
 
+func (lc *noopLimitsRangeCalculator) GetNamespaceResourceBounds(namespace string) (*Bounds, error) {
+	return &Bounds{}, nil
+}
+
 type limitsChecker struct {
-	limitRangeLister listers.LimitRangeLister
+	limitRangeLister    listers.LimitRangeLister
+	resourceQuotaLister listers.ResourceQuotaLister
 }
 
 // NewLimitsRangeCalculator returns a limitsChecker or an error it encountered when attempting to create it.
@@ -64,6 +97,9 @@ func NewLimitsRangeCalculator(f informers.SharedInformerFactory) (*limitsChecker
 	//	Informer() cache.SharedIndexInformer
 	//	Lister() v1.LimitRangeLister
 	//}
+	resourceQuotaLister := f.Core().V1().ResourceQuotas().Lister()
+	// 同样地，从同一个SharedInformerFactory中创建resourceQuota这个Informer实例，
+	// 用来获取命名空间中的ResourceQuota对象，进而推导出admission真正允许的headroom
 	stopCh := make(chan struct{})
 	f.Start(stopCh)
 	// 启动f中注册的所有Informer，该步骤必须在注册Informer之后。
@@ -76,9 +112,12 @@ func NewLimitsRangeCalculator(f informers.SharedInformerFactory) (*limitsChecker
 				// 如果informer的sync没有同步对象，则报错
 				return nil, fmt.Errorf("informer did not sync")
 			}
+			if !f.Core().V1().ResourceQuotas().Informer().HasSynced() {
+				return nil, fmt.Errorf("informer did not sync")
+			}
 		}
 	}
-	return &limitsChecker{limitRangeLister}, nil
+	return &limitsChecker{limitRangeLister, resourceQuotaLister}, nil
 }
 
 // NewNoopLimitsCalculator returns a limit calculator that instantly returns no limits.
@@ -150,3 +189,94 @@ func (lc *limitsChecker) getLimitRangeItem(namespace string, limitType core.Limi
 	}
 	return nil, nil
 }
+
+func pickLowerMaxQuantity(q1, q2 resource.Quantity) resource.Quantity {
+	if q1.Cmp(q2) < 0 {
+		return q1
+	}
+	return q2
+}
+
+// GetNamespaceResourceBounds returns the container/pod LimitRangeItem caps
+// together with the cap derived from the namespace's ResourceQuota headroom,
+// so callers can apply all three together rather than risking a
+// recommendation that LimitRange allows but ResourceQuota would reject.
+// GetNamespaceResourceBounds返回container/pod级别的LimitRangeItem上限，以及从该
+// 命名空间ResourceQuota headroom推导出的上限，这样调用者可以把三者一起应用，
+// 而不是冒着LimitRange允许但ResourceQuota会拒绝的recommendation的风险。
+func (lc *limitsChecker) GetNamespaceResourceBounds(namespace string) (*Bounds, error) {
+	containerItem, err := lc.GetContainerLimitRangeItem(namespace)
+	if err != nil {
+		return nil, err
+	}
+	podItem, err := lc.GetPodLimitRangeItem(namespace)
+	if err != nil {
+		return nil, err
+	}
+	quotaItem, err := lc.getQuotaHeadroomItem(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Bounds{Container: containerItem, Pod: podItem, Quota: quotaItem}, nil
+}
+
+// getQuotaHeadroomItem subtracts status.used from status.hard for every
+// ResourceQuota in namespace (across all scopes - BestEffort, NotTerminating,
+// PriorityClass included, since they share the same limits.*/requests.*
+// resource names) and returns the smallest remaining headroom per resource as
+// a container-shaped LimitRangeItem.Max, ready to be further capped against
+// the existing LimitRange max via pickLowerMaxQuantity.
+// getQuotaHeadroomItem对命名空间中的每一个ResourceQuota（包括BestEffort、
+// NotTerminating、PriorityClass等各个scope的变体，因为它们共用相同的
+// limits.*/requests.*资源名）用status.hard减去status.used，
+// 并把每种资源剩余的最小headroom以container形状的LimitRangeItem.Max返回，
+// 随后可以再通过pickLowerMaxQuantity与已有的LimitRange max取更低值。
+func (lc *limitsChecker) getQuotaHeadroomItem(namespace string) (*core.LimitRangeItem, error) {
+	quotas, err := lc.resourceQuotaLister.ResourceQuotas(namespace).List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error loading resource quotas: %s", err)
+	}
+	result := &core.LimitRangeItem{Type: core.LimitTypeContainer}
+	for _, quota := range quotas {
+		for _, resourceName := range quotaScopedResources {
+			hard, hasHard := quota.Status.Hard[resourceName]
+			if !hasHard {
+				continue
+			}
+			used := quota.Status.Used[resourceName]
+			headroom := hard.DeepCopy()
+			headroom.Sub(used)
+			if headroom.Sign() < 0 {
+				headroom = resource.MustParse("0")
+			}
+
+			effectiveName := quotaResourceToLimitRangeResource(resourceName)
+			if result.Max == nil {
+				result.Max = core.ResourceList{}
+			}
+			if existing, has := result.Max[effectiveName]; has {
+				result.Max[effectiveName] = pickLowerMaxQuantity(existing, headroom)
+			} else {
+				result.Max[effectiveName] = headroom
+			}
+		}
+	}
+	if result.Max == nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// quotaResourceToLimitRangeResource maps the ResourceQuota's limits.cpu/
+// limits.memory/requests.cpu/requests.memory resource names onto the plain
+// cpu/memory names LimitRangeItem.Max uses.
+func quotaResourceToLimitRangeResource(name core.ResourceName) core.ResourceName {
+	switch name {
+	case core.ResourceLimitsCPU, core.ResourceRequestsCPU:
+		return core.ResourceCPU
+	case core.ResourceLimitsMemory, core.ResourceRequestsMemory:
+		return core.ResourceMemory
+	default:
+		return name
+	}
+}